@@ -1,81 +1,324 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
 	"os"
-	"sync"
+	"os/signal"
+	"path/filepath"
+	"sync/atomic"
+	"syscall"
+	"time"
 
+	"faast-go/internal/calibrate"
+	"faast-go/internal/checkpoint"
 	"faast-go/internal/config"
 	"faast-go/internal/curl"
+	"faast-go/internal/match"
+	"faast-go/internal/output"
 	"faast-go/internal/permute"
+	"faast-go/internal/wordlist"
 	"faast-go/internal/worker"
 
 	"github.com/schollz/progressbar/v3"
 )
 
 func main() {
-	if len(os.Args) < 2 {
+	outputFile := flag.String("o", "", "write hits to `file`")
+	flag.Parse()
+	args := flag.Args()
+	if len(args) < 1 {
 		log.Fatal("Please provide a YAML config file")
 	}
 
-	loadedConfig, err := config.LoadConfig(os.Args[1])
+	configFile := args[0]
+	checkpointFile := ""
+	var state *checkpoint.Checkpoint
+	if args[0] == "resume" {
+		if len(args) < 2 {
+			log.Fatal("Please provide a checkpoint file to resume")
+		}
+		checkpointFile = args[1]
+
+		var err error
+		state, err = checkpoint.Load(checkpointFile)
+		if err != nil {
+			log.Fatalf("Error loading checkpoint: %v", err)
+		}
+		if err := state.Verify(); err != nil {
+			log.Fatalf("Cannot resume: %v", err)
+		}
+		configFile = state.ConfigFile
+	}
+
+	loadedConfig, err := config.LoadConfig(configFile)
 	if err != nil {
 		log.Fatalf("Error loading config: %v", err)
 	}
+	if checkpointFile == "" {
+		checkpointFile = loadedConfig.Checkpoint
+	}
+	if state != nil {
+		if err := state.CheckWordlists(loadedConfig.FieldWordlists()); err != nil {
+			log.Fatalf("Cannot resume: %v", err)
+		}
+	}
+
+	runCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	interrupted := cancelOnSignal(cancel)
+	if loadedConfig.MaxDuration != "" {
+		maxDuration, _ := time.ParseDuration(loadedConfig.MaxDuration)
+		runCtx, cancel = context.WithTimeout(runCtx, maxDuration)
+		defer cancel()
+	}
 
 	curlConfig, err := curl.NewCurlConfig(loadedConfig)
 	if err != nil {
 		log.Fatalf("Error creating curl config: %v", err)
 	}
 
-	wordlists, err := config.LoadWordlists(loadedConfig.Wordlists)
+	replayer, err := curl.NewReplayer(curlConfig, loadedConfig.ReplayProxy)
+	if err != nil {
+		log.Fatalf("Error creating replay proxy: %v", err)
+	}
+
+	matcher, err := match.New(loadedConfig)
+	if err != nil {
+		log.Fatalf("Error creating matcher: %v", err)
+	}
+
+	if loadedConfig.Calibrate.Enabled {
+		baselines, err := calibrate.LoadOrRun(runCtx, curlConfig, loadedConfig.Calibrate)
+		if err != nil {
+			log.Fatalf("Error calibrating: %v", err)
+		}
+		for _, baseline := range baselines {
+			fmt.Printf("Calibration: filtering responses with %s\n", baseline)
+			matcher.AddFilter(baseline)
+		}
+	}
+
+	wordlists, err := wordlist.Load(loadedConfig.ListFields())
 	if err != nil {
 		log.Fatalf("Error loading wordlists: %v", err)
 	}
+	defer wordlists.Close()
 
-	shardedLists := permute.ShardLists(wordlists, loadedConfig.ShardIndex, loadedConfig.NumShards)
+	iterator, err := permute.NewModeIterator(wordlists, loadedConfig.Mode, loadedConfig.FieldDefaults())
+	if err != nil {
+		log.Fatalf("Error loading wordlists: %v", err)
+	}
+	start, end := permute.ShardRange(iterator.Total(), loadedConfig.ShardIndex, loadedConfig.NumShards)
 
-	totalPermutations := permute.CalculateTotalPermutations(shardedLists)
-	progressBar := progressbar.Default(int64(totalPermutations))
+	if state == nil && checkpointFile != "" {
+		absConfigFile, err := filepath.Abs(configFile)
+		if err != nil {
+			log.Fatalf("Error creating checkpoint: %v", err)
+		}
+		state, err = checkpoint.New(absConfigFile, loadedConfig.FieldWordlists(), start, end)
+		if err != nil {
+			log.Fatalf("Error creating checkpoint: %v", err)
+		}
+	} else if state != nil {
+		fmt.Printf("Resuming: %d of %d permutations done, %d hits so far\n", state.Done(), state.End-state.Start, len(state.Hits))
+	}
 
-	permChan := make(chan []string, 10000)
-	resultChan := make(chan worker.CurlResult, 1000)
+	fields := loadedConfig.PayloadFields()
+	console, err := output.New(os.Stdout, output.Text, fields)
+	if err != nil {
+		log.Fatalf("Error creating output: %v", err)
+	}
+	writers := []*output.Writer{console}
+	if *outputFile != "" {
+		// A resumed run adds to the hits already written by the earlier runs.
+		fileWriter, err := output.Create(*outputFile, output.FormatFor(loadedConfig.Format, *outputFile), fields, state != nil && state.Done() > 0)
+		if err != nil {
+			log.Fatalf("Error creating output: %v", err)
+		}
+		defer fileWriter.Close()
+		writers = append(writers, fileWriter)
+	}
 
-	var wg sync.WaitGroup
-	for _, list := range shardedLists {
-		wg.Add(1)
-		go func(l [][]string) {
-			defer wg.Done()
-			permute.IteratePermutations(permute.NewPermutationIterator(l), permChan)
-		}(list)
+	ranges := []permute.Range{{Start: start, End: end}}
+	stopCheckpoints := func() {}
+	if state != nil {
+		ranges = state.Remaining()
+		stopCheckpoints = saveCheckpoints(state, checkpointFile, time.Duration(loadedConfig.CheckpointInterval)*time.Second)
 	}
 
+	total := 0
+	for _, r := range ranges {
+		total += r.End - r.Start
+	}
+	progressBar := progressbar.Default(int64(total))
+
+	permChan := make(chan permute.Permutation, 10000)
+	resultChan := make(chan worker.CurlResult, 1000)
+
 	go func() {
-		wg.Wait()
+		permute.IterateRanges(runCtx, iterator, ranges, permChan)
+		if err := iterator.Err(); err != nil {
+			fmt.Printf("\nError loading wordlists: %v, waiting for in-flight requests to finish\n", err)
+			cancel()
+		}
 		close(permChan)
 	}()
 
 	workerPool := worker.NewWorkerPool(curlConfig, permChan, resultChan, progressBar)
-	workerPool.Start()
+	workerPool.SetWorkers(loadedConfig.Workers)
+	workerPool.SetRetry(worker.NewRetryPolicy(loadedConfig.Retry))
+	if loadedConfig.Adaptive.Enabled {
+		workerPool.SetAdaptive(loadedConfig.Adaptive)
+	}
+	workerPool.Start(runCtx)
+
+	startTime := time.Now()
+	finished := make(chan struct{})
+	go func() {
+		select {
+		case <-runCtx.Done():
+			if errors.Is(runCtx.Err(), context.DeadlineExceeded) {
+				fmt.Printf("\nmaxDuration of %s reached, waiting for in-flight requests to finish\n", loadedConfig.MaxDuration)
+			}
+		case <-finished:
+		}
+	}()
 
 	go func() {
 		workerPool.Wait()
 		close(resultChan)
 	}()
 
-	ProcessResults(resultChan, curlConfig)
+	summary := output.NewSummary()
+	failed := ProcessResults(resultChan, matcher, replayer, state, fields, writers, summary)
+	close(finished)
+	replayer.Wait()
+	stopCheckpoints()
+	if runCtx.Err() != nil {
+		progressBar.Exit()
+	}
+
+	summary.Finish(time.Since(startTime))
+	for _, writer := range writers {
+		if err := writer.WriteSummary(summary); err != nil {
+			fmt.Printf("Warning: %v\n", err)
+		}
+	}
+	if len(failed) > 0 && loadedConfig.Retry.FailedFile != "" {
+		if err := saveFailed(loadedConfig.Retry.FailedFile, configFile, loadedConfig.FieldWordlists(), start, end, failed); err != nil {
+			fmt.Printf("Warning: %v\n", err)
+		} else {
+			fmt.Printf("%d payloads failed, send them again with: resume %s\n", len(failed), loadedConfig.Retry.FailedFile)
+		}
+	}
+	if runCtx.Err() != nil && state != nil {
+		fmt.Printf("Stopped early, continue with: resume %s\n", checkpointFile)
+	}
+	if interrupted.Load() {
+		os.Exit(130)
+	}
+	if iterator.Err() != nil {
+		os.Exit(1)
+	}
+}
+
+// cancelOnSignal calls cancel on the first SIGINT or SIGTERM and reports
+// whether that happened. After the first signal the default handling is
+// restored, so a second Ctrl-C kills the process without waiting for the
+// in-flight requests.
+func cancelOnSignal(cancel context.CancelFunc) *atomic.Bool {
+	interrupted := &atomic.Bool{}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		signal.Stop(signals)
+		interrupted.Store(true)
+		fmt.Printf("\nInterrupted, waiting for in-flight requests to finish\n")
+		cancel()
+	}()
+	return interrupted
 }
 
-func ProcessResults(resultChan <-chan worker.CurlResult, loadedConfig *curl.CurlConfig) {
+// ProcessResults reads results until resultChan is closed, so every result
+// of a cancelled run is still reported and checkpointed. Hits are sent again
+// through the replay proxy, if there is one. It returns the permutations
+// that failed.
+func ProcessResults(resultChan <-chan worker.CurlResult, matcher *match.Engine, replayer *curl.Replayer, state *checkpoint.Checkpoint, fields []string, writers []*output.Writer, summary *output.Summary) []checkpoint.Hit {
+	var failed []checkpoint.Hit
 	for result := range resultChan {
 		if result.Err != nil {
+			failed = append(failed, checkpoint.Hit{Index: result.Index, Payload: result.Payload})
+			summary.Add(nil, result.Err, false)
 			fmt.Printf("Error: %v\n", result.Err)
 			continue
 		}
-		if !loadedConfig.ValidateResponse(result.Response) {
-			fmt.Printf("Payload %v caused an anomaly\n", result.Payload)
-			result.Response.Body.Close()
+		hit := matcher.IsHit(result.Response)
+		summary.Add(result.Response, nil, hit)
+		if hit {
+			record := output.NewRecord(result.Index, fields, result.Payload, result.Response)
+			for _, writer := range writers {
+				if err := writer.Write(record); err != nil {
+					fmt.Printf("Warning: %v\n", err)
+				}
+			}
+			if state != nil {
+				state.AddHit(result.Index, result.Payload)
+			}
+			replayer.Replay(result.Request)
 		}
+		if state != nil {
+			state.MarkDone(result.Index)
+		}
+	}
+	return failed
+}
+
+// saveFailed writes a checkpoint in which only the failed permutations are
+// left to do, so they can be sent again with the resume command.
+func saveFailed(filename string, configFile string, wordlists []string, start, end int, failed []checkpoint.Hit) error {
+	absConfigFile, err := filepath.Abs(configFile)
+	if err != nil {
+		return err
+	}
+	job, err := checkpoint.New(absConfigFile, wordlists, start, end)
+	if err != nil {
+		return err
+	}
+	job.Retry(failed)
+	return job.Save(filename)
+}
+
+// saveCheckpoints saves the checkpoint every interval. The returned function
+// stops the periodic saves and writes the final checkpoint.
+func saveCheckpoints(state *checkpoint.Checkpoint, filename string, interval time.Duration) func() {
+	save := func() {
+		if err := state.Save(filename); err != nil {
+			fmt.Printf("Warning: %v\n", err)
+		}
+	}
+
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				save()
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		ticker.Stop()
+		close(done)
+		save()
 	}
 }
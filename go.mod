@@ -2,12 +2,15 @@ module faast-go
 
 go 1.22.5
 
+require (
+	github.com/schollz/progressbar/v3 v3.15.0
+	golang.org/x/time v0.6.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
 require (
 	github.com/mitchellh/colorstring v0.0.0-20190213212951-d06e56a500db // indirect
 	github.com/rivo/uniseg v0.4.7 // indirect
-	github.com/schollz/progressbar/v3 v3.15.0 // indirect
 	golang.org/x/sys v0.25.0 // indirect
 	golang.org/x/term v0.24.0 // indirect
-	golang.org/x/time v0.6.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -0,0 +1,283 @@
+package calibrate
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"faast-go/internal/config"
+	"faast-go/internal/curl"
+)
+
+// Baseline is the fingerprint of a "normal" response. Values that changed
+// between calibration requests are left nil and match anything.
+type Baseline struct {
+	StatusCode int               `json:"status"`
+	Size       *int              `json:"size,omitempty"`
+	Words      *int              `json:"words,omitempty"`
+	Lines      *int              `json:"lines,omitempty"`
+	Headers    map[string]string `json:"headers,omitempty"`
+}
+
+func (b *Baseline) Match(res *curl.Response) bool {
+	if res.StatusCode != b.StatusCode {
+		return false
+	}
+	if b.Size != nil && res.Size() != *b.Size {
+		return false
+	}
+	if b.Words != nil && res.Words() != *b.Words {
+		return false
+	}
+	if b.Lines != nil && res.Lines() != *b.Lines {
+		return false
+	}
+	for name, value := range b.Headers {
+		if res.Header.Get(name) != value {
+			return false
+		}
+	}
+	return true
+}
+
+func (b *Baseline) String() string {
+	parts := []string{fmt.Sprintf("status=%d", b.StatusCode)}
+	for _, metric := range []struct {
+		name  string
+		value *int
+	}{{"size", b.Size}, {"words", b.Words}, {"lines", b.Lines}} {
+		if metric.value != nil {
+			parts = append(parts, fmt.Sprintf("%s=%d", metric.name, *metric.value))
+		}
+	}
+	names := make([]string, 0, len(b.Headers))
+	for name := range b.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		parts = append(parts, fmt.Sprintf("%s=%q", name, b.Headers[name]))
+	}
+	return strings.Join(parts, " ")
+}
+
+// Run sends random canary permutations through the normal request path and
+// returns a baseline for every distinct status code seen. A status code whose
+// responses share no size, word or line count gets no baseline, as it would
+// otherwise filter out every response with that status.
+//
+// Canaries fit the type of the fields they are placed in, and a sample that
+// stays throttled is skipped.
+func Run(ctx context.Context, c *curl.CurlConfig, settings config.CalibrateConfig) ([]*Baseline, error) {
+	types := c.SourceTypes()
+	var dir string
+	if slices.Contains(types, "file") {
+		var err error
+		dir, err = os.MkdirTemp("", "faast-calibrate")
+		if err != nil {
+			return nil, fmt.Errorf("error creating calibration files: %w", err)
+		}
+		defer os.RemoveAll(dir)
+	}
+
+	var responses []*curl.Response
+	for i := 0; i < settings.Samples; i++ {
+		permutation := make([]string, len(types))
+		for j, fieldType := range types {
+			value, err := typedCanary(fieldType, 8*(i+1), dir)
+			if err != nil {
+				return nil, err
+			}
+			permutation[j] = value
+		}
+		payload, err := c.ConstructPayload(permutation)
+		if err != nil {
+			return nil, fmt.Errorf("error constructing calibration payload: %w", err)
+		}
+		res, err := send(ctx, c, payload)
+		if errors.Is(err, curl.ErrThrottled) {
+			fmt.Printf("Warning: calibration request %d was throttled, skipping it\n", i+1)
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error sending calibration request: %w", err)
+		}
+		responses = append(responses, res)
+	}
+
+	byStatus := make(map[int][]*curl.Response)
+	var statuses []int
+	for _, res := range responses {
+		if _, ok := byStatus[res.StatusCode]; !ok {
+			statuses = append(statuses, res.StatusCode)
+		}
+		byStatus[res.StatusCode] = append(byStatus[res.StatusCode], res)
+	}
+
+	var baselines []*Baseline
+	for _, status := range statuses {
+		baseline := fingerprint(byStatus[status], settings.Headers)
+		if baseline.Size == nil && baseline.Words == nil && baseline.Lines == nil {
+			fmt.Printf("Warning: calibration responses with status %d are not stable, not filtering them\n", status)
+			continue
+		}
+		baselines = append(baselines, baseline)
+	}
+	return baselines, nil
+}
+
+// send sends a calibration request, again while it is throttled, up to the
+// throttle's Retries. SendCurl itself waits until the throttle pause ends.
+func send(ctx context.Context, c *curl.CurlConfig, payload *curl.Payload) (*curl.Response, error) {
+	for throttled := 0; ; throttled++ {
+		res, err := c.SendCurl(ctx, payload)
+		if !errors.Is(err, curl.ErrThrottled) || throttled >= c.Throttle.Retries || ctx.Err() != nil {
+			return res, err
+		}
+	}
+}
+
+func fingerprint(responses []*curl.Response, headers []string) *Baseline {
+	baseline := &Baseline{StatusCode: responses[0].StatusCode}
+	baseline.Size = stable(responses, (*curl.Response).Size)
+	baseline.Words = stable(responses, (*curl.Response).Words)
+	baseline.Lines = stable(responses, (*curl.Response).Lines)
+
+	for _, name := range headers {
+		value := responses[0].Header.Get(name)
+		same := true
+		for _, res := range responses[1:] {
+			if res.Header.Get(name) != value {
+				same = false
+				break
+			}
+		}
+		if same {
+			if baseline.Headers == nil {
+				baseline.Headers = make(map[string]string)
+			}
+			baseline.Headers[http.CanonicalHeaderKey(name)] = value
+		}
+	}
+	return baseline
+}
+
+func stable(responses []*curl.Response, metric func(*curl.Response) int) *int {
+	value := metric(responses[0])
+	for _, res := range responses[1:] {
+		if metric(res) != value {
+			return nil
+		}
+	}
+	return &value
+}
+
+const canaryChars = "abcdefghijklmnopqrstuvwxyz0123456789"
+
+func canary(length int) string {
+	b := make([]byte, length)
+	for i := range b {
+		b[i] = canaryChars[rand.IntN(len(canaryChars))]
+	}
+	return string(b)
+}
+
+// typedCanary is a canary that parses as a value of fieldType. A file field
+// gets the path of a file in dir holding the canary.
+func typedCanary(fieldType string, length int, dir string) (string, error) {
+	switch fieldType {
+	case "int":
+		return digits(min(length, 18)), nil
+	case "float":
+		return digits(min(length, 14)/2) + "." + digits(min(length, 14)/2), nil
+	case "bool":
+		return "true", nil
+	case "json":
+		return strconv.Quote(canary(length)), nil
+	case "file":
+		f, err := os.CreateTemp(dir, "canary")
+		if err != nil {
+			return "", fmt.Errorf("error creating calibration file: %w", err)
+		}
+		defer f.Close()
+		if _, err := f.WriteString(canary(length)); err != nil {
+			return "", fmt.Errorf("error writing calibration file: %w", err)
+		}
+		return f.Name(), nil
+	default:
+		return canary(length), nil
+	}
+}
+
+// digits is a random number with length digits.
+func digits(length int) string {
+	b := make([]byte, length)
+	for i := range b {
+		b[i] = '0' + byte(rand.IntN(10))
+	}
+	b[0] = '1' + byte(rand.IntN(9))
+	return string(b)
+}
+
+type calibrationFile struct {
+	Baselines []*Baseline `json:"baselines"`
+}
+
+// Load reads baselines stored by Save. It returns os.ErrNotExist (wrapped)
+// when there is no stored calibration yet.
+func Load(filename string) ([]*Baseline, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("error reading calibration file: %w", err)
+	}
+	var file calibrationFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("error parsing calibration file: %w", err)
+	}
+	return file.Baselines, nil
+}
+
+func Save(filename string, baselines []*Baseline) error {
+	data, err := json.MarshalIndent(calibrationFile{Baselines: baselines}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding calibration: %w", err)
+	}
+	if err := os.WriteFile(filename, data, 0644); err != nil {
+		return fmt.Errorf("error writing calibration file: %w", err)
+	}
+	return nil
+}
+
+// LoadOrRun reuses the stored calibration when there is one, and otherwise
+// runs a new calibration and stores it if a file is configured.
+func LoadOrRun(ctx context.Context, c *curl.CurlConfig, settings config.CalibrateConfig) ([]*Baseline, error) {
+	if settings.File != "" {
+		baselines, err := Load(settings.File)
+		if err == nil {
+			fmt.Printf("Loaded calibration from %s\n", settings.File)
+			return baselines, nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+	}
+
+	baselines, err := Run(ctx, c, settings)
+	if err != nil {
+		return nil, err
+	}
+	if settings.File != "" {
+		if err := Save(settings.File, baselines); err != nil {
+			return nil, err
+		}
+	}
+	return baselines, nil
+}
@@ -0,0 +1,239 @@
+package calibrate
+
+import (
+	"context"
+	"faast-go/internal/config"
+	"faast-go/internal/curl"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func newCurlConfig(t *testing.T, endpoint string) *curl.CurlConfig {
+	t.Helper()
+	return newCurlConfigFrom(t, &config.YamlConfig{
+		Endpoint: endpoint,
+		Fields: []config.Field{
+			{Name: "user", Wordlist: "users.txt"},
+			{Name: "pass", Wordlist: "passwords.txt"},
+		},
+	})
+}
+
+func newCurlConfigFrom(t *testing.T, settings *config.YamlConfig) *curl.CurlConfig {
+	t.Helper()
+	settings.SetDefaults()
+	c, err := curl.NewCurlConfig(settings)
+	if err != nil {
+		t.Fatalf("NewCurlConfig failed: %v", err)
+	}
+	return c
+}
+
+func intPtr(n int) *int {
+	return &n
+}
+
+func TestRun(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Location", "/login")
+		w.WriteHeader(http.StatusUnauthorized)
+		// the body reflects the payload, so only the word and line counts are stable
+		fmt.Fprintf(w, "invalid login for %s\n", body)
+	}))
+	defer server.Close()
+
+	settings := config.CalibrateConfig{Samples: 3, Headers: []string{"location"}}
+	baselines, err := Run(context.Background(), newCurlConfig(t, server.URL), settings)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	want := []*Baseline{{
+		StatusCode: http.StatusUnauthorized,
+		Words:      intPtr(4),
+		Lines:      intPtr(1),
+		Headers:    map[string]string{"Location": "/login"},
+	}}
+	if !reflect.DeepEqual(baselines, want) {
+		t.Errorf("Run() = %v, want %v", baselines, want)
+	}
+}
+
+func TestRun_UnstableStatus(t *testing.T) {
+	count := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count++
+		// every response has a different size, word and line count
+		fmt.Fprint(w, strings.Repeat("word\n", count))
+	}))
+	defer server.Close()
+
+	baselines, err := Run(context.Background(), newCurlConfig(t, server.URL), config.CalibrateConfig{Samples: 3})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if len(baselines) != 0 {
+		t.Errorf("Run() should not return a baseline for unstable responses, got %v", baselines)
+	}
+}
+
+func TestRun_FieldTypes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprint(w, "invalid login")
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name     string
+		bodyType string
+		fields   []config.Field
+	}{
+		{
+			name:     "JSON values",
+			bodyType: "json",
+			fields: []config.Field{
+				{Name: "id", Wordlist: "ids.txt", Type: "int"},
+				{Name: "price", Wordlist: "prices.txt", Type: "float"},
+				{Name: "admin", Wordlist: "flags.txt", Type: "bool"},
+				{Name: "meta", Wordlist: "meta.txt", Type: "json"},
+			},
+		},
+		{
+			name:     "File upload",
+			bodyType: "multipart",
+			fields: []config.Field{
+				{Name: "user", Wordlist: "users.txt"},
+				{Name: "avatar", Wordlist: "avatars.txt", Type: "file"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newCurlConfigFrom(t, &config.YamlConfig{Endpoint: server.URL, BodyType: tt.bodyType, Fields: tt.fields})
+			baselines, err := Run(context.Background(), c, config.CalibrateConfig{Samples: 2})
+			if err != nil {
+				t.Fatalf("Run failed: %v", err)
+			}
+			if len(baselines) != 1 || baselines[0].StatusCode != http.StatusUnauthorized {
+				t.Errorf("Run() = %v, want a baseline for status 401", baselines)
+			}
+		})
+	}
+}
+
+func TestRun_Throttled(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		// the first sample is throttled twice, the second one for good
+		if requests <= 2 || requests >= 4 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, "not found")
+	}))
+	defer server.Close()
+
+	c := newCurlConfigFrom(t, &config.YamlConfig{
+		Endpoint:  server.URL,
+		RateLimit: 1000,
+		Throttle:  config.ThrottleConfig{Status: []int{429}, Pause: "1ms", Retries: 2},
+		Fields:    []config.Field{{Name: "user", Wordlist: "users.txt"}},
+	})
+	baselines, err := Run(context.Background(), c, config.CalibrateConfig{Samples: 2})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if requests != 6 {
+		t.Errorf("expected 6 calibration requests, got %d", requests)
+	}
+	want := "status=404 size=9 words=2 lines=1"
+	if len(baselines) != 1 || baselines[0].String() != want {
+		t.Errorf("Run() = %v, want %s", baselines, want)
+	}
+}
+
+func TestBaseline_Match(t *testing.T) {
+	baseline := &Baseline{StatusCode: 404, Size: intPtr(9), Headers: map[string]string{"Content-Type": "text/plain"}}
+	tests := []struct {
+		name string
+		res  *curl.Response
+		want bool
+	}{
+		{
+			name: "Same response",
+			res:  &curl.Response{StatusCode: 404, Header: http.Header{"Content-Type": {"text/plain"}}, Body: []byte("not found")},
+			want: true,
+		},
+		{
+			name: "Different status",
+			res:  &curl.Response{StatusCode: 200, Header: http.Header{"Content-Type": {"text/plain"}}, Body: []byte("not found")},
+			want: false,
+		},
+		{
+			name: "Different size",
+			res:  &curl.Response{StatusCode: 404, Header: http.Header{"Content-Type": {"text/plain"}}, Body: []byte("nope")},
+			want: false,
+		},
+		{
+			name: "Different header",
+			res:  &curl.Response{StatusCode: 404, Header: http.Header{"Content-Type": {"text/html"}}, Body: []byte("not found")},
+			want: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := baseline.Match(tt.res); got != tt.want {
+				t.Errorf("Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadOrRun(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, "not found")
+	}))
+	defer server.Close()
+
+	settings := config.CalibrateConfig{Samples: 2, File: filepath.Join(t.TempDir(), "calibration.json")}
+	c := newCurlConfig(t, server.URL)
+
+	first, err := LoadOrRun(context.Background(), c, settings)
+	if err != nil {
+		t.Fatalf("LoadOrRun failed: %v", err)
+	}
+	if requests != 2 {
+		t.Errorf("expected 2 calibration requests, got %d", requests)
+	}
+
+	second, err := LoadOrRun(context.Background(), c, settings)
+	if err != nil {
+		t.Fatalf("LoadOrRun failed: %v", err)
+	}
+	if requests != 2 {
+		t.Errorf("stored calibration should be reused, got %d requests", requests)
+	}
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("reused calibration = %v, want %v", second, first)
+	}
+
+	want := "status=404 size=9 words=2 lines=1"
+	if len(second) != 1 || second[0].String() != want {
+		t.Errorf("unexpected baseline %v, want %s", second, want)
+	}
+}
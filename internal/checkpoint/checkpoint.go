@@ -0,0 +1,239 @@
+package checkpoint
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"faast-go/internal/permute"
+)
+
+type Hit struct {
+	Index   int      `json:"index"`
+	Payload []string `json:"payload"`
+}
+
+// Checkpoint is the state of a run: which permutations of the range
+// [Start, End) are done, and the hits found so far. The hashes of the config
+// and wordlists make sure a resumed run sends the same permutations.
+type Checkpoint struct {
+	ConfigFile string            `json:"configFile"`
+	ConfigHash string            `json:"configHash"`
+	Wordlists  map[string]string `json:"wordlists"`
+	Start      int               `json:"start"`
+	End        int               `json:"end"`
+	Completed  []permute.Range   `json:"completed"`
+	Hits       []Hit             `json:"hits"`
+	Failed     []Hit             `json:"failed,omitempty"`
+
+	mu sync.Mutex
+}
+
+// New creates the checkpoint of a run over [start, end). Wordlists are stored
+// with absolute paths, so the checkpoint can be verified from any directory.
+func New(configFile string, wordlists []string, start, end int) (*Checkpoint, error) {
+	c := &Checkpoint{
+		ConfigFile: configFile,
+		Wordlists:  make(map[string]string, len(wordlists)),
+		Start:      start,
+		End:        end,
+	}
+
+	var err error
+	if c.ConfigHash, err = hashFile(configFile); err != nil {
+		return nil, err
+	}
+	for _, wordlist := range wordlists {
+		path, err := filepath.Abs(wordlist)
+		if err != nil {
+			return nil, fmt.Errorf("error resolving %s: %w", wordlist, err)
+		}
+		if c.Wordlists[path], err = hashFile(path); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+func Load(filename string) (*Checkpoint, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("error reading checkpoint file: %w", err)
+	}
+	var c Checkpoint
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("error parsing checkpoint file: %w", err)
+	}
+	return &c, nil
+}
+
+// Verify checks that the config and wordlists have not changed since the
+// checkpoint was written.
+func (c *Checkpoint) Verify() error {
+	hash, err := hashFile(c.ConfigFile)
+	if err != nil {
+		return err
+	}
+	if hash != c.ConfigHash {
+		return fmt.Errorf("config file %s changed since the checkpoint", c.ConfigFile)
+	}
+	for wordlist, want := range c.Wordlists {
+		hash, err := hashFile(wordlist)
+		if err != nil {
+			return err
+		}
+		if hash != want {
+			return fmt.Errorf("wordlist %s changed since the checkpoint", wordlist)
+		}
+	}
+	return nil
+}
+
+// CheckWordlists checks that the given wordlists, relative to the working
+// directory, are the ones the checkpoint was written for. A resumed run opens
+// the wordlists of the config from the working directory, so starting it from
+// another directory would read other files.
+func (c *Checkpoint) CheckWordlists(wordlists []string) error {
+	for _, wordlist := range wordlists {
+		path, err := filepath.Abs(wordlist)
+		if err != nil {
+			return fmt.Errorf("error resolving %s: %w", wordlist, err)
+		}
+		if _, ok := c.Wordlists[path]; !ok {
+			return fmt.Errorf("wordlist %s is not in the checkpoint, resume from the directory of the first run", path)
+		}
+	}
+	return nil
+}
+
+// Save writes the checkpoint to a temporary file first, so an interrupted
+// save never leaves a truncated checkpoint behind.
+func (c *Checkpoint) Save(filename string) error {
+	c.mu.Lock()
+	data, err := json.MarshalIndent(c, "", "  ")
+	c.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("error encoding checkpoint: %w", err)
+	}
+
+	tmp := filename + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("error writing checkpoint file: %w", err)
+	}
+	if err := os.Rename(tmp, filename); err != nil {
+		return fmt.Errorf("error writing checkpoint file: %w", err)
+	}
+	return nil
+}
+
+// MarkDone records that the permutation with the given index is done.
+// Adjacent indices are merged, so the completed ranges stay few even when
+// workers finish out of order.
+func (c *Checkpoint) MarkDone(index int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// i is the first range starting after index
+	i := sort.Search(len(c.Completed), func(i int) bool { return c.Completed[i].Start > index })
+	if i > 0 && index < c.Completed[i-1].End {
+		return
+	}
+
+	joinsPrev := i > 0 && c.Completed[i-1].End == index
+	joinsNext := i < len(c.Completed) && c.Completed[i].Start == index+1
+	switch {
+	case joinsPrev && joinsNext:
+		c.Completed[i-1].End = c.Completed[i].End
+		c.Completed = append(c.Completed[:i], c.Completed[i+1:]...)
+	case joinsPrev:
+		c.Completed[i-1].End++
+	case joinsNext:
+		c.Completed[i].Start--
+	default:
+		c.Completed = append(c.Completed, permute.Range{})
+		copy(c.Completed[i+1:], c.Completed[i:])
+		c.Completed[i] = permute.Range{Start: index, End: index + 1}
+	}
+}
+
+func (c *Checkpoint) AddHit(index int, payload []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Hits = append(c.Hits, Hit{Index: index, Payload: payload})
+}
+
+// Retry turns the checkpoint into a job for the failed permutations only:
+// every permutation is marked done except the failed ones, so resuming the
+// checkpoint sends exactly those again.
+func (c *Checkpoint) Retry(failed []Hit) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.Failed = append([]Hit(nil), failed...)
+	sort.Slice(c.Failed, func(i, j int) bool { return c.Failed[i].Index < c.Failed[j].Index })
+	c.Hits = nil
+	c.Completed = nil
+	next := c.Start
+	for _, hit := range c.Failed {
+		if hit.Index > next {
+			c.Completed = append(c.Completed, permute.Range{Start: next, End: hit.Index})
+		}
+		next = max(next, hit.Index+1)
+	}
+	if next < c.End {
+		c.Completed = append(c.Completed, permute.Range{Start: next, End: c.End})
+	}
+}
+
+// Done is the number of completed permutations.
+func (c *Checkpoint) Done() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	done := 0
+	for _, r := range c.Completed {
+		done += r.End - r.Start
+	}
+	return done
+}
+
+// Remaining returns the ranges of [Start, End) that are not completed yet.
+func (c *Checkpoint) Remaining() []permute.Range {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var remaining []permute.Range
+	next := c.Start
+	for _, r := range c.Completed {
+		if r.Start > next {
+			remaining = append(remaining, permute.Range{Start: next, End: min(r.Start, c.End)})
+		}
+		next = max(next, r.End)
+		if next >= c.End {
+			break
+		}
+	}
+	if next < c.End {
+		remaining = append(remaining, permute.Range{Start: next, End: c.End})
+	}
+	return remaining
+}
+
+func hashFile(filename string) (string, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return "", fmt.Errorf("error opening %s: %w", filename, err)
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", fmt.Errorf("error hashing %s: %w", filename, err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
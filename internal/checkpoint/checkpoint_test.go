@@ -0,0 +1,184 @@
+package checkpoint
+
+import (
+	"faast-go/internal/permute"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMarkDone(t *testing.T) {
+	c := &Checkpoint{Start: 0, End: 20}
+	for _, index := range []int{5, 3, 4, 10, 12, 11, 0, 4, 19} {
+		c.MarkDone(index)
+	}
+
+	want := []permute.Range{
+		{Start: 0, End: 1},
+		{Start: 3, End: 6},
+		{Start: 10, End: 13},
+		{Start: 19, End: 20},
+	}
+	if !reflect.DeepEqual(c.Completed, want) {
+		t.Errorf("Completed = %v, want %v", c.Completed, want)
+	}
+	if c.Done() != 8 {
+		t.Errorf("Done() = %d, want 8", c.Done())
+	}
+
+	wantRemaining := []permute.Range{
+		{Start: 1, End: 3},
+		{Start: 6, End: 10},
+		{Start: 13, End: 19},
+	}
+	if got := c.Remaining(); !reflect.DeepEqual(got, wantRemaining) {
+		t.Errorf("Remaining() = %v, want %v", got, wantRemaining)
+	}
+}
+
+func TestRemaining(t *testing.T) {
+	tests := []struct {
+		name      string
+		start     int
+		end       int
+		completed []permute.Range
+		want      []permute.Range
+	}{
+		{
+			name:  "Nothing done",
+			start: 10,
+			end:   20,
+			want:  []permute.Range{{Start: 10, End: 20}},
+		},
+		{
+			name:      "Everything done",
+			start:     10,
+			end:       20,
+			completed: []permute.Range{{Start: 10, End: 20}},
+			want:      nil,
+		},
+		{
+			name:      "Done at the start of a shard",
+			start:     10,
+			end:       20,
+			completed: []permute.Range{{Start: 10, End: 15}},
+			want:      []permute.Range{{Start: 15, End: 20}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Checkpoint{Start: tt.start, End: tt.end, Completed: tt.completed}
+			if got := c.Remaining(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Remaining() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetry(t *testing.T) {
+	c := &Checkpoint{Start: 10, End: 20, Hits: []Hit{{Index: 11, Payload: []string{"hit"}}}}
+	c.Retry([]Hit{
+		{Index: 15, Payload: []string{"b"}},
+		{Index: 10, Payload: []string{"a"}},
+		{Index: 16, Payload: []string{"c"}},
+	})
+
+	want := []permute.Range{{Start: 10, End: 11}, {Start: 15, End: 17}}
+	if got := c.Remaining(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Remaining() = %v, want %v", got, want)
+	}
+	if c.Hits != nil {
+		t.Errorf("Hits = %v, want none", c.Hits)
+	}
+	if c.Failed[0].Index != 10 || c.Failed[2].Index != 16 {
+		t.Errorf("Failed = %v, want sorted by index", c.Failed)
+	}
+}
+
+func TestSaveLoadVerify(t *testing.T) {
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "config.yml")
+	wordlist := filepath.Join(dir, "words.txt")
+	if err := os.WriteFile(configFile, []byte("endpoint: http://example.com\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(wordlist, []byte("a\nb\nc\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := New(configFile, []string{wordlist, wordlist}, 0, 3)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	c.MarkDone(1)
+	c.AddHit(1, []string{"b"})
+
+	checkpointFile := filepath.Join(dir, "run.ckpt")
+	if err := c.Save(checkpointFile); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	loaded, err := Load(checkpointFile)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if !reflect.DeepEqual(loaded.Completed, c.Completed) || !reflect.DeepEqual(loaded.Hits, c.Hits) {
+		t.Errorf("Load() = %+v, want %+v", loaded, c)
+	}
+	if len(loaded.Wordlists) != 1 {
+		t.Errorf("expected one hashed wordlist, got %v", loaded.Wordlists)
+	}
+	if err := loaded.Verify(); err != nil {
+		t.Errorf("Verify() on unchanged files failed: %v", err)
+	}
+
+	if err := os.WriteFile(wordlist, []byte("a\nb\nc\nd\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := loaded.Verify(); err == nil {
+		t.Error("Verify() should fail when a wordlist changed")
+	}
+
+	if _, err := Load(filepath.Join(dir, "missing.ckpt")); err == nil {
+		t.Error("Load() should fail for a missing checkpoint")
+	}
+}
+
+func TestVerify_OtherDirectory(t *testing.T) {
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "config.yml")
+	if err := os.WriteFile(configFile, []byte("endpoint: http://example.com\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "words.txt"), []byte("a\nb\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	c, err := New(configFile, []string{"words.txt"}, 0, 2)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if err := c.CheckWordlists([]string{"words.txt"}); err != nil {
+		t.Errorf("CheckWordlists() in the same directory failed: %v", err)
+	}
+
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Verify(); err != nil {
+		t.Errorf("Verify() from another directory failed: %v", err)
+	}
+	if err := c.CheckWordlists([]string{"words.txt"}); err == nil {
+		t.Error("CheckWordlists() should fail from another directory")
+	}
+}
@@ -1,54 +1,292 @@
 package config
 
 import (
-	"bufio"
 	"fmt"
 	"math"
+	"net"
+	"net/url"
 	"os"
+	"slices"
+	"strconv"
+	"text/template/parse"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
 
 type YamlConfig struct {
-	Type         string   `yaml:"type"`
-	Endpoint     string   `yaml:"endpoint"`
-	Fields       []string `yaml:"fields"`
-	Wordlists    []string `yaml:"wordlists"`
-	StaticValues []string `yaml:"staticValues"`
-	Cookies      []string `yaml:"cookies"`
-	ValidateType string   `yaml:"validateType"`
-	SizeDefault  int      `yaml:"sizeDefault"`
-	CodeDefault  int      `yaml:"codeDefault"`
-	RateLimit    float64  `yaml:"rateLimit"`
-	Timeout      int      `yaml:"timeout"`
-	ShardIndex   int      `yaml:"shardIndex"`
-	NumShards    int      `yaml:"numShards"`
-}
-
-func LoadWordlists(filenames []string) ([][]string, error) {
-	wordlists := make([][]string, len(filenames))
-	for i, filename := range filenames {
-		file, err := os.Open(filename)
-		if err != nil {
-			return nil, fmt.Errorf("error opening wordlist file %s: %w", filename, err)
-		}
-		defer file.Close()
+	Type               string            `yaml:"type"`
+	Endpoint           string            `yaml:"endpoint"`
+	Request            string            `yaml:"request"`
+	Scheme             string            `yaml:"scheme"`
+	Method             string            `yaml:"method"`
+	BodyType           string            `yaml:"bodyType"`
+	Fields             []Field           `yaml:"fields"`
+	Wordlists          []string          `yaml:"wordlists"`
+	StaticValues       []string          `yaml:"staticValues"`
+	Headers            []string          `yaml:"headers"`
+	UserAgents         []string          `yaml:"userAgents"`
+	UserAgentFile      string            `yaml:"userAgentFile"`
+	UserAgentRotation  string            `yaml:"userAgentRotation"`
+	RemoveHeaders      []string          `yaml:"removeHeaders"`
+	Cookies            []string          `yaml:"cookies"`
+	Body               string            `yaml:"body"`
+	Mode               string            `yaml:"mode"`
+	Match              Rules             `yaml:"match"`
+	Filter             Rules             `yaml:"filter"`
+	Calibrate          CalibrateConfig   `yaml:"calibrate"`
+	ValidateType       string            `yaml:"validateType"`
+	SizeDefault        int               `yaml:"sizeDefault"`
+	CodeDefault        int               `yaml:"codeDefault"`
+	RateLimit          float64           `yaml:"rateLimit"`
+	Timeout            int               `yaml:"timeout"`
+	ShardIndex         int               `yaml:"shardIndex"`
+	NumShards          int               `yaml:"numShards"`
+	Checkpoint         string            `yaml:"checkpoint"`
+	CheckpointInterval int               `yaml:"checkpointInterval"`
+	MaxDuration        string            `yaml:"maxDuration"`
+	Format             string            `yaml:"format"`
+	Workers            int               `yaml:"workers"`
+	Adaptive           AdaptiveConfig    `yaml:"adaptive"`
+	Retry              RetryConfig       `yaml:"retry"`
+	Throttle           ThrottleConfig    `yaml:"throttle"`
+	Proxy              string            `yaml:"proxy"`
+	Proxies            []string          `yaml:"proxies"`
+	ProxyHealth        ProxyHealthConfig `yaml:"proxyHealth"`
+	ReplayProxy        string            `yaml:"replayProxy"`
+	TLS                TLSConfig         `yaml:"tls"`
+	// ResolveHosts maps "host:port" (or "host" for any port) to the IP address
+	// connected to instead, like curl --resolve. DNSServer is the DNS server
+	// other hosts are resolved with, and UnixSocket the socket every request
+	// is sent to instead of a TCP connection.
+	ResolveHosts map[string]string `yaml:"resolve"`
+	DNSServer    string            `yaml:"dnsServer"`
+	UnixSocket   string            `yaml:"unixSocket"`
+}
 
-		var words []string
-		scanner := bufio.NewScanner(file)
-		for scanner.Scan() {
-			words = append(words, scanner.Text())
+// Field is a named value that can be placed anywhere in the request with a
+// {{name}} marker. A field takes its values from a wordlist, an inline list
+// of Values or a generator (Range, Mask or Dates), is a static Value, takes
+// the value of the field named by Ref, or computes it from the other fields.
+// Fields with a Ref share the source of the field they refer to, so they
+// always hold the same value. Type controls how the value is encoded in json
+// and multipart bodies, and Default is the value used in sniper mode while
+// another field is being attacked. Rules are hashcat-style rules every value
+// is mutated with.
+type Field struct {
+	Name     string   `yaml:"name"`
+	Wordlist string   `yaml:"wordlist"`
+	Values   []string `yaml:"values"`
+	Value    string   `yaml:"value"`
+	Ref      string   `yaml:"ref"`
+	Type     string   `yaml:"type"`
+	Default  string   `yaml:"default"`
+	Rules    []string `yaml:"rules"`
+	// Encoders are applied to the value in order, and their result is
+	// placed as it is, without the escaping of the place it goes to.
+	Encoders []string `yaml:"encoders"`
+	// Range is "from-to", such as "0000-9999". Values are padded to the
+	// width of from and go up (or down) by Step.
+	Range string `yaml:"range"`
+	Step  int    `yaml:"step"`
+	// Mask is a hashcat mask such as "?u?l?l?d?d". Charsets defines the
+	// custom charsets ?1 to ?4.
+	Mask     string            `yaml:"mask"`
+	Charsets map[string]string `yaml:"charsets"`
+	Dates    DatesConfig       `yaml:"dates"`
+	// Compute is a text/template computing the value from the other fields
+	// of the permutation, such as "{{ md5 .user .pass }}".
+	Compute string `yaml:"compute"`
+}
+
+// DatesConfig generates the dates from From to To, both included, every
+// Step ("1d" by default, or a duration such as "1h"), formatted with the Go
+// layout Format ("2006-01-02" by default).
+type DatesConfig struct {
+	From   string `yaml:"from"`
+	To     string `yaml:"to"`
+	Step   string `yaml:"step"`
+	Format string `yaml:"format"`
+}
+
+// IsList reports whether the field has a list of values of its own: a
+// wordlist, inline values or a generator.
+func (f Field) IsList() bool {
+	return f.Wordlist != "" || len(f.Values) > 0 || f.Range != "" || f.Mask != "" || f.Dates.From != ""
+}
+
+// sources returns the number of value sources the field has.
+func (f Field) sources() int {
+	n := 0
+	for _, set := range []bool{f.Wordlist != "", len(f.Values) > 0, f.Value != "", f.Ref != "", f.Compute != "", f.Range != "", f.Mask != "", f.Dates.From != ""} {
+		if set {
+			n++
 		}
+	}
+	return n
+}
 
-		if err := scanner.Err(); err != nil {
-			return nil, fmt.Errorf("error reading wordlist file %s: %w", filename, err)
+// ComputeFields returns the names of the fields the Compute template refers
+// to as .name or $.name. Functions are not checked here, only when the
+// template is parsed to be executed.
+func (f Field) ComputeFields() ([]string, error) {
+	tree := parse.New(f.Name)
+	tree.Mode = parse.SkipFuncCheck
+	if _, err := tree.Parse(f.Compute, "", "", map[string]*parse.Tree{}); err != nil {
+		return nil, err
+	}
+	var names []string
+	var walk func(node parse.Node)
+	walk = func(node parse.Node) {
+		switch node := node.(type) {
+		case *parse.ListNode:
+			if node != nil {
+				for _, n := range node.Nodes {
+					walk(n)
+				}
+			}
+		case *parse.ActionNode:
+			walk(node.Pipe)
+		case *parse.PipeNode:
+			if node != nil {
+				for _, cmd := range node.Cmds {
+					walk(cmd)
+				}
+			}
+		case *parse.CommandNode:
+			for _, arg := range node.Args {
+				walk(arg)
+			}
+		case *parse.FieldNode:
+			names = append(names, node.Ident[0])
+		case *parse.VariableNode:
+			if len(node.Ident) > 1 && node.Ident[0] == "$" {
+				names = append(names, node.Ident[1])
+			}
+		case *parse.ChainNode:
+			walk(node.Node)
+		case *parse.IfNode:
+			walk(&node.BranchNode)
+		case *parse.RangeNode:
+			walk(&node.BranchNode)
+		case *parse.WithNode:
+			walk(&node.BranchNode)
+		case *parse.BranchNode:
+			walk(node.Pipe)
+			walk(node.List)
+			walk(node.ElseList)
+		case *parse.TemplateNode:
+			walk(node.Pipe)
 		}
+	}
+	walk(tree.Root)
+	return names, nil
+}
 
-		wordlists[i] = words
+// UnmarshalYAML accepts either a bare field name (the positional format,
+// bound later by MigrateFields) or a mapping with an explicit source.
+func (f *Field) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*f = Field{Name: node.Value}
+		return nil
 	}
-	return wordlists, nil
+	type plain Field
+	return node.Decode((*plain)(f))
+}
+
+// Rules select responses. Each list holds values or ranges such as "200",
+// "300-399", "1000-" or "-100ms". With mode "or" (the default) a response is
+// selected when any of the configured rules matches it, with "and" when all
+// of them do.
+type Rules struct {
+	Mode        string   `yaml:"mode"`
+	Status      []string `yaml:"status"`
+	Size        []string `yaml:"size"`
+	Words       []string `yaml:"words"`
+	Lines       []string `yaml:"lines"`
+	Time        []string `yaml:"time"`
+	Regex       []string `yaml:"regex"`
+	HeaderRegex []string `yaml:"headerRegex"`
+	Headers     []string `yaml:"headers"`
+}
+
+// CalibrateConfig controls auto-calibration: before fuzzing, Samples random
+// permutations are sent and responses looking like them are filtered out.
+// Headers are compared as part of the fingerprint, and File stores the
+// result so later runs can reuse it.
+type CalibrateConfig struct {
+	Enabled bool     `yaml:"enabled"`
+	Samples int      `yaml:"samples"`
+	Headers []string `yaml:"headers"`
+	File    string   `yaml:"file"`
+}
+
+// AdaptiveConfig lets the worker pool change how many workers are active
+// while running, between MinWorkers and MaxWorkers. The count is halved when
+// the target answers 429 or 503, requests time out, or the average response
+// time goes above MaxLatency (without it, above twice the fastest average
+// seen), and grows by one worker otherwise.
+type AdaptiveConfig struct {
+	Enabled    bool   `yaml:"enabled"`
+	MinWorkers int    `yaml:"minWorkers"`
+	MaxWorkers int    `yaml:"maxWorkers"`
+	MaxLatency string `yaml:"maxLatency"`
+}
+
+// RetryConfig controls how often a request is sent before its permutation
+// is given up on. Requests failing with one of the Errors kinds (see
+// curl.ErrorKind) or answered with one of the Status codes are retried after
+// an exponential backoff with jitter, or after the Retry-After delay when the
+// response has one. Permutations that still fail are written to FailedFile.
+type RetryConfig struct {
+	Attempts   int      `yaml:"attempts"`
+	Backoff    string   `yaml:"backoff"`
+	MaxBackoff string   `yaml:"maxBackoff"`
+	Status     []int    `yaml:"status"`
+	Errors     []string `yaml:"errors"`
+	FailedFile string   `yaml:"failedFile"`
 }
 
+// ThrottleConfig controls what happens when the target rate limits us. A
+// response with one of the Status codes, or with a body containing one of
+// the Signatures (case insensitive), halves the request rate down to MinRate
+// and pauses all requests for Pause, or for the Retry-After delay of the
+// response. The rate is raised again after every Pause without throttling.
+// A throttled request is sent again up to Retries times.
+type ThrottleConfig struct {
+	Status     []int    `yaml:"status"`
+	Signatures []string `yaml:"signatures"`
+	Pause      string   `yaml:"pause"`
+	MinRate    float64  `yaml:"minRate"`
+	Retries    int      `yaml:"retries"`
+}
+
+// ProxyHealthConfig controls the eviction of dead proxies from the pool. A
+// proxy that cannot be connected to Failures times in a row is evicted, and
+// checked again every Interval until it accepts connections.
+type ProxyHealthConfig struct {
+	Failures int    `yaml:"failures"`
+	Interval string `yaml:"interval"`
+}
+
+// TLSConfig controls the TLS connections to the target. CA is a PEM bundle
+// trusted on top of the system roots, Cert and Key a PEM client certificate
+// for mutual TLS, and ServerName the name sent with SNI and verified instead
+// of the host of the URL. MinVersion and MaxVersion are "1.0" to "1.3".
+type TLSConfig struct {
+	InsecureSkipVerify bool   `yaml:"insecureSkipVerify"`
+	CA                 string `yaml:"ca"`
+	Cert               string `yaml:"cert"`
+	Key                string `yaml:"key"`
+	ServerName         string `yaml:"serverName"`
+	MinVersion         string `yaml:"minVersion"`
+	MaxVersion         string `yaml:"maxVersion"`
+}
+
+// tlsVersions are the TLS versions that can be given as MinVersion and
+// MaxVersion, in order.
+var tlsVersions = []string{"1.0", "1.1", "1.2", "1.3"}
+
 func LoadConfig(filename string) (*YamlConfig, error) {
 	file, err := os.ReadFile(filename)
 	if err != nil {
@@ -65,26 +303,404 @@ func LoadConfig(filename string) (*YamlConfig, error) {
 		return nil, fmt.Errorf("invalid config: %w", err)
 	}
 
+	config.MigrateFields()
 	config.SetDefaults()
 
 	return &config, nil
 }
 
 func (c *YamlConfig) Validate() error {
-	if c.Endpoint == "" {
-		return fmt.Errorf("endpoint is required")
+	if c.Endpoint == "" && c.Request == "" {
+		return fmt.Errorf("endpoint or request is required")
+	}
+	if c.Endpoint != "" && c.Request != "" {
+		return fmt.Errorf("endpoint cannot be used with request")
 	}
-	fmt.Println(c.Fields)
-	fmt.Println(c.Wordlists)
-	fmt.Println(c.StaticValues)
-	if c.Type == "payload" && len(c.Fields) != (len(c.Wordlists)+len(c.StaticValues)) {
-		return fmt.Errorf("number of fields must equal number of wordlists + staticValues")
+	switch c.Scheme {
+	case "", "http", "https":
+	default:
+		return fmt.Errorf("invalid scheme %q", c.Scheme)
+	}
+
+	seen := make(map[string]bool, len(c.Fields))
+	for _, field := range c.Fields {
+		if field.Name == "" {
+			return fmt.Errorf("field name is required")
+		}
+		if seen[field.Name] {
+			return fmt.Errorf("duplicate field %q", field.Name)
+		}
+		seen[field.Name] = true
+		if field.sources() > 1 {
+			return fmt.Errorf("field %q can only have one of wordlist, values, value, ref, compute, range, mask and dates", field.Name)
+		}
+		if len(field.Rules) > 0 && !field.IsList() {
+			return fmt.Errorf("field %q cannot have rules without a wordlist or generator", field.Name)
+		}
+		for _, encoder := range field.Encoders {
+			switch encoder {
+			case "none", "url", "double-url", "base64", "base64url", "hex", "html-entity", "json-escape",
+				"unicode", "md5", "sha1", "sha256":
+			default:
+				return fmt.Errorf("field %q has invalid encoder %q", field.Name, encoder)
+			}
+		}
+		if field.Step < 0 {
+			return fmt.Errorf("field %q step cannot be negative", field.Name)
+		}
+		if (field.Dates.From == "") != (field.Dates.To == "") {
+			return fmt.Errorf("field %q dates need both from and to", field.Name)
+		}
+		switch field.Type {
+		case "", "string", "int", "float", "bool", "null", "json", "file":
+		default:
+			return fmt.Errorf("field %q has invalid type %q", field.Name, field.Type)
+		}
+	}
+	for _, field := range c.Fields {
+		if field.Compute == "" {
+			continue
+		}
+		names, err := field.ComputeFields()
+		if err != nil {
+			return fmt.Errorf("field %q has invalid compute: %w", field.Name, err)
+		}
+		for _, name := range names {
+			if !seen[name] {
+				return fmt.Errorf("field %q computes from unknown field %q", field.Name, name)
+			}
+		}
+	}
+	for _, field := range c.Fields {
+		if field.Ref == "" {
+			continue
+		}
+		if !seen[field.Ref] {
+			return fmt.Errorf("field %q refers to unknown field %q", field.Name, field.Ref)
+		}
+		resolved, ok := c.resolve(field)
+		if !ok {
+			return fmt.Errorf("field %q refers to itself through ref", field.Name)
+		}
+		if resolved.Compute != "" {
+			return fmt.Errorf("field %q cannot refer to computed field %q", field.Name, resolved.Name)
+		}
+	}
+
+	switch c.UserAgentRotation {
+	case "", "request", "worker", "random":
+	default:
+		return fmt.Errorf("invalid userAgentRotation %q", c.UserAgentRotation)
+	}
+
+	switch c.Mode {
+	case "", "clusterbomb", "pitchfork", "batteringram", "sniper":
+	default:
+		return fmt.Errorf("invalid mode %q", c.Mode)
+	}
+	if c.NumShards < 0 || c.ShardIndex < 0 || c.ShardIndex >= max(c.NumShards, 1) {
+		return fmt.Errorf("shardIndex must be between 0 and numShards - 1")
+	}
+
+	if c.CheckpointInterval < 0 {
+		return fmt.Errorf("checkpointInterval cannot be negative")
+	}
+
+	if c.MaxDuration != "" {
+		if _, err := time.ParseDuration(c.MaxDuration); err != nil {
+			return fmt.Errorf("invalid maxDuration %q", c.MaxDuration)
+		}
+	}
+
+	if c.Workers < 0 {
+		return fmt.Errorf("workers cannot be negative")
+	}
+	if c.Adaptive.MinWorkers < 0 || c.Adaptive.MaxWorkers < 0 {
+		return fmt.Errorf("adaptive minWorkers and maxWorkers cannot be negative")
+	}
+	if c.Adaptive.MaxWorkers > 0 && c.Adaptive.MinWorkers > c.Adaptive.MaxWorkers {
+		return fmt.Errorf("adaptive minWorkers cannot be above maxWorkers")
+	}
+	if c.Adaptive.MaxLatency != "" {
+		if _, err := time.ParseDuration(c.Adaptive.MaxLatency); err != nil {
+			return fmt.Errorf("invalid adaptive maxLatency %q", c.Adaptive.MaxLatency)
+		}
+	}
+
+	if c.Retry.Attempts < 0 {
+		return fmt.Errorf("retry attempts cannot be negative")
+	}
+	for _, d := range []string{c.Retry.Backoff, c.Retry.MaxBackoff} {
+		if d == "" {
+			continue
+		}
+		if _, err := time.ParseDuration(d); err != nil {
+			return fmt.Errorf("invalid retry backoff %q", d)
+		}
+	}
+	for _, kind := range c.Retry.Errors {
+		switch kind {
+		case "throttled", "timeout", "dns", "connection refused", "connection reset", "other":
+		default:
+			return fmt.Errorf("invalid retry error kind %q", kind)
+		}
+	}
+
+	if c.Throttle.Pause != "" {
+		if _, err := time.ParseDuration(c.Throttle.Pause); err != nil {
+			return fmt.Errorf("invalid throttle pause %q", c.Throttle.Pause)
+		}
+	}
+	if c.Throttle.MinRate < 0 || c.Throttle.Retries < 0 {
+		return fmt.Errorf("throttle minRate and retries cannot be negative")
+	}
+
+	if c.Proxy != "" && len(c.Proxies) > 0 {
+		return fmt.Errorf("proxy cannot be used with proxies")
+	}
+	for _, proxy := range append([]string{c.Proxy, c.ReplayProxy}, c.Proxies...) {
+		if proxy == "" {
+			continue
+		}
+		u, err := url.Parse(proxy)
+		if err != nil || u.Host == "" {
+			return fmt.Errorf("invalid proxy %q", proxy)
+		}
+		switch u.Scheme {
+		case "http", "https", "socks5", "socks5h":
+		default:
+			return fmt.Errorf("invalid proxy scheme %q", u.Scheme)
+		}
+	}
+	if c.ProxyHealth.Failures < 0 {
+		return fmt.Errorf("proxyHealth failures cannot be negative")
+	}
+	if c.ProxyHealth.Interval != "" {
+		if _, err := time.ParseDuration(c.ProxyHealth.Interval); err != nil {
+			return fmt.Errorf("invalid proxyHealth interval %q", c.ProxyHealth.Interval)
+		}
+	}
+
+	for target, ip := range c.ResolveHosts {
+		if host, port, err := net.SplitHostPort(target); err == nil {
+			if _, err := strconv.ParseUint(port, 10, 16); err != nil || host == "" {
+				return fmt.Errorf("invalid resolve target %q", target)
+			}
+		}
+		if net.ParseIP(ip) == nil {
+			return fmt.Errorf("invalid resolve address %q for %s", ip, target)
+		}
+	}
+	// A proxy connects to the target itself, so it would ignore them.
+	if c.UnixSocket != "" && (c.Proxy != "" || len(c.Proxies) > 0) {
+		return fmt.Errorf("unixSocket cannot be used with a proxy")
+	}
+	if (len(c.ResolveHosts) > 0 || c.DNSServer != "") && (c.Proxy != "" || len(c.Proxies) > 0) {
+		return fmt.Errorf("resolve and dnsServer cannot be used with a proxy")
+	}
+
+	if (c.TLS.Cert == "") != (c.TLS.Key == "") {
+		return fmt.Errorf("tls cert and key must be given together")
+	}
+	versions := make([]int, 2)
+	for i, version := range []string{c.TLS.MinVersion, c.TLS.MaxVersion} {
+		if version == "" {
+			continue
+		}
+		versions[i] = slices.Index(tlsVersions, version) + 1
+		if versions[i] == 0 {
+			return fmt.Errorf("invalid tls version %q", version)
+		}
+	}
+	if versions[0] > 0 && versions[1] > 0 && versions[0] > versions[1] {
+		return fmt.Errorf("tls minVersion cannot be above maxVersion")
+	}
+
+	switch c.Format {
+	case "", "text", "jsonl", "csv":
+	default:
+		return fmt.Errorf("invalid format %q", c.Format)
+	}
+
+	if c.Calibrate.Samples < 0 {
+		return fmt.Errorf("calibrate samples cannot be negative")
+	}
+
+	for _, mode := range []string{c.Match.Mode, c.Filter.Mode} {
+		if mode != "" && mode != "and" && mode != "or" {
+			return fmt.Errorf("invalid match mode %q", mode)
+		}
+	}
+
+	switch c.BodyType {
+	case "", "query", "form", "json", "raw":
+	case "multipart":
+		if c.Body != "" {
+			return fmt.Errorf("body cannot be used with bodyType multipart")
+		}
+	default:
+		return fmt.Errorf("invalid bodyType %q", c.BodyType)
+	}
+	if c.BodyType == "query" && c.Body != "" {
+		return fmt.Errorf("body cannot be used with bodyType query")
+	}
+
+	if c.positionalFields() {
+		if c.Type == "payload" && len(c.Fields) != (len(c.Wordlists)+len(c.StaticValues)) {
+			return fmt.Errorf("number of fields must equal number of wordlists + staticValues")
+		}
+	} else if len(c.Wordlists) > 0 || len(c.StaticValues) > 0 {
+		return fmt.Errorf("wordlists and staticValues can only be used with plain field names")
 	}
 
 	return nil
 }
 
+// positionalFields reports whether the config uses the old format, where
+// field N binds to wordlist N and the staticValues fill the remaining fields.
+func (c *YamlConfig) positionalFields() bool {
+	if len(c.Wordlists) == 0 && len(c.StaticValues) == 0 {
+		return false
+	}
+	for _, field := range c.Fields {
+		if field.sources() > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// MigrateFields binds positional fields to their wordlists and static values
+// so the rest of the program only has to deal with explicit sources.
+func (c *YamlConfig) MigrateFields() {
+	if !c.positionalFields() {
+		return
+	}
+	for i := range c.Fields {
+		switch {
+		case i < len(c.Wordlists):
+			c.Fields[i].Wordlist = c.Wordlists[i]
+		case i-len(c.Wordlists) < len(c.StaticValues):
+			c.Fields[i].Value = c.StaticValues[i-len(c.Wordlists)]
+		}
+	}
+	c.Wordlists = nil
+	c.StaticValues = nil
+}
+
+// FieldWordlists returns the wordlist file of every field that has one, in
+// field order.
+func (c *YamlConfig) FieldWordlists() []string {
+	var wordlists []string
+	for _, field := range c.Fields {
+		if field.Wordlist != "" {
+			wordlists = append(wordlists, field.Wordlist)
+		}
+	}
+	return wordlists
+}
+
+// ListFields returns every field with a list of its own, in field order.
+// This is the order values appear in a permutation. Two fields only share a
+// list through a Ref, even when they read the same wordlist.
+func (c *YamlConfig) ListFields() []Field {
+	var fields []Field
+	for _, field := range c.Fields {
+		if field.IsList() {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// FieldSources returns, for every field, the index in ListFields of the
+// field it takes its values from, or -1 when it is static.
+func (c *YamlConfig) FieldSources() []int {
+	index := make(map[string]int)
+	for i, field := range c.ListFields() {
+		index[field.Name] = i
+	}
+	sources := make([]int, len(c.Fields))
+	for i, field := range c.Fields {
+		sources[i] = -1
+		if field = c.Resolve(field); field.IsList() {
+			sources[i] = index[field.Name]
+		}
+	}
+	return sources
+}
+
+// Resolve follows the refs of field to the field its values come from.
+func (c *YamlConfig) Resolve(field Field) Field {
+	field, _ = c.resolve(field)
+	return field
+}
+
+// resolve is Resolve, and reports false when the refs go in a circle.
+func (c *YamlConfig) resolve(field Field) (Field, bool) {
+	for range c.Fields {
+		if field.Ref == "" {
+			return field, true
+		}
+		for _, other := range c.Fields {
+			if other.Name == field.Ref {
+				field = other
+				break
+			}
+		}
+	}
+	return field, field.Ref == ""
+}
+
+// PayloadFields returns the name of every list field, in the same order as
+// ListFields.
+func (c *YamlConfig) PayloadFields() []string {
+	var names []string
+	for _, field := range c.ListFields() {
+		names = append(names, field.Name)
+	}
+	return names
+}
+
+// FieldDefaults returns the default of every list field, in the same order as
+// ListFields.
+func (c *YamlConfig) FieldDefaults() []string {
+	var defaults []string
+	for _, field := range c.ListFields() {
+		defaults = append(defaults, field.Default)
+	}
+	return defaults
+}
+
 func (c *YamlConfig) SetDefaults() {
+	if c.BodyType == "" {
+		c.BodyType = "form"
+		if c.Body != "" || c.Request != "" {
+			c.BodyType = "raw"
+		}
+	}
+	if c.Method == "" && c.Request == "" {
+		c.Method = "POST"
+		if c.BodyType == "query" {
+			c.Method = "GET"
+		}
+	}
+	for i := range c.Fields {
+		if dates := &c.Fields[i].Dates; dates.From != "" {
+			if dates.Step == "" {
+				dates.Step = "1d"
+			}
+			if dates.Format == "" {
+				dates.Format = "2006-01-02"
+			}
+		}
+	}
+	if c.Scheme == "" && c.Request != "" {
+		c.Scheme = "https"
+	}
+	if c.UserAgentRotation == "" {
+		c.UserAgentRotation = "request"
+	}
 	if c.CodeDefault == 0 {
 		c.CodeDefault = 404
 	}
@@ -97,4 +713,55 @@ func (c *YamlConfig) SetDefaults() {
 	if c.NumShards == 0 {
 		c.NumShards = 1
 	}
+	if c.CheckpointInterval == 0 {
+		c.CheckpointInterval = 30
+	}
+	if c.Workers == 0 {
+		c.Workers = 10
+	}
+	if c.Adaptive.MinWorkers == 0 {
+		c.Adaptive.MinWorkers = 1
+	}
+	if c.Adaptive.MaxWorkers == 0 {
+		c.Adaptive.MaxWorkers = max(100, c.Workers)
+	}
+	if c.Retry.Attempts == 0 {
+		c.Retry.Attempts = 1
+	}
+	if c.Retry.Backoff == "" {
+		c.Retry.Backoff = "500ms"
+	}
+	if c.Retry.MaxBackoff == "" {
+		c.Retry.MaxBackoff = "30s"
+	}
+	if c.Retry.Status == nil {
+		c.Retry.Status = []int{429, 502, 503, 504}
+	}
+	if c.Retry.Errors == nil {
+		c.Retry.Errors = []string{"timeout", "connection refused", "connection reset"}
+	}
+	if c.Throttle.Status == nil {
+		c.Throttle.Status = []int{429, 503}
+	}
+	if c.Throttle.Pause == "" {
+		c.Throttle.Pause = "10s"
+	}
+	if c.Throttle.MinRate == 0 {
+		c.Throttle.MinRate = 1
+	}
+	if c.Throttle.Retries == 0 {
+		c.Throttle.Retries = 10
+	}
+	if c.Calibrate.Samples == 0 {
+		c.Calibrate.Samples = 4
+	}
+	if c.ProxyHealth.Failures == 0 {
+		c.ProxyHealth.Failures = 3
+	}
+	if c.ProxyHealth.Interval == "" {
+		c.ProxyHealth.Interval = "30s"
+	}
+	if c.Calibrate.Headers == nil {
+		c.Calibrate.Headers = []string{"Location"}
+	}
 }
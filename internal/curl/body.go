@@ -0,0 +1,182 @@
+package curl
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// encodeBody places the body fields (the fields not placed by a marker) into
+// the payload according to the body type, and sets the matching Content-Type
+// unless one was given in the headers. A multipart body always sets its own.
+func (c *CurlConfig) encodeBody(payload *Payload, values []string) error {
+	contentType := ""
+	switch c.BodyType {
+	case "query":
+		query := c.formEncode(values)
+		if query != "" {
+			if strings.Contains(payload.URL, "?") {
+				payload.URL += "&" + query
+			} else {
+				payload.URL += "?" + query
+			}
+		}
+	case "json":
+		contentType = "application/json"
+		if c.bodyTemplate != nil {
+			payload.Body = c.bodyTemplate.render(values, c.escaper(jsonEscape))
+			break
+		}
+		body, err := c.jsonEncode(values)
+		if err != nil {
+			return err
+		}
+		payload.Body = body
+	case "multipart":
+		body, boundary, err := c.multipartEncode(values)
+		if err != nil {
+			return err
+		}
+		payload.Body = body
+		contentType = "multipart/form-data; boundary=" + boundary
+	case "raw":
+		if c.bodyTemplate != nil {
+			payload.Body = c.bodyTemplate.render(values, c.escaper(nil))
+		}
+	default:
+		contentType = "application/x-www-form-urlencoded"
+		if c.bodyTemplate != nil {
+			payload.Body = c.bodyTemplate.render(values, c.escaper(url.QueryEscape))
+		} else {
+			payload.Body = c.formEncode(values)
+		}
+	}
+
+	// A multipart Content-Type has to give the boundary of this body.
+	if contentType != "" && (payload.Header.Get("Content-Type") == "" || c.BodyType == "multipart") {
+		payload.Header.Set("Content-Type", contentType)
+	}
+	return nil
+}
+
+func (c *CurlConfig) formEncode(values []string) string {
+	escape := c.escaper(url.QueryEscape)
+	var body strings.Builder
+	for i, field := range c.bodyFields {
+		if i > 0 {
+			body.WriteString("&")
+		}
+		body.WriteString(url.QueryEscape(c.Fields[field]) + "=" + escape(field, values[field]))
+	}
+	return body.String()
+}
+
+// jsonEncode builds a JSON object from the body fields. Dots in a field name
+// nest the value, so "user.name" becomes {"user": {"name": ...}}.
+func (c *CurlConfig) jsonEncode(values []string) (string, error) {
+	root := make(map[string]any)
+	for _, field := range c.bodyFields {
+		value, err := typedValue(values[field], c.fieldTypes[field])
+		if err != nil {
+			return "", fmt.Errorf("field %s: %w", c.Fields[field], err)
+		}
+		if err := setPath(root, strings.Split(c.Fields[field], "."), value); err != nil {
+			return "", fmt.Errorf("field %s: %w", c.Fields[field], err)
+		}
+	}
+
+	var body bytes.Buffer
+	encoder := json.NewEncoder(&body)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(root); err != nil {
+		return "", fmt.Errorf("error encoding json body: %w", err)
+	}
+	return strings.TrimSuffix(body.String(), "\n"), nil
+}
+
+func setPath(object map[string]any, path []string, value any) error {
+	for _, key := range path[:len(path)-1] {
+		next, ok := object[key]
+		if !ok {
+			child := make(map[string]any)
+			object[key] = child
+			object = child
+			continue
+		}
+		child, ok := next.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s is both a value and an object", key)
+		}
+		object = child
+	}
+	key := path[len(path)-1]
+	if _, ok := object[key]; ok {
+		return fmt.Errorf("%s is set more than once", key)
+	}
+	object[key] = value
+	return nil
+}
+
+func typedValue(value string, fieldType string) (any, error) {
+	switch fieldType {
+	case "int":
+		return strconv.ParseInt(value, 10, 64)
+	case "float":
+		return strconv.ParseFloat(value, 64)
+	case "bool":
+		return strconv.ParseBool(value)
+	case "null":
+		return nil, nil
+	case "json":
+		if !json.Valid([]byte(value)) {
+			return nil, fmt.Errorf("invalid json value %q", value)
+		}
+		return json.RawMessage(value), nil
+	default:
+		return value, nil
+	}
+}
+
+func (c *CurlConfig) multipartEncode(values []string) (string, string, error) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	for _, field := range c.bodyFields {
+		if c.fieldTypes[field] != "file" {
+			if err := writer.WriteField(c.Fields[field], values[field]); err != nil {
+				return "", "", fmt.Errorf("error writing multipart field: %w", err)
+			}
+			continue
+		}
+		content, err := os.ReadFile(values[field])
+		if err != nil {
+			return "", "", fmt.Errorf("error reading file for field %s: %w", c.Fields[field], err)
+		}
+		part, err := writer.CreateFormFile(c.Fields[field], filepath.Base(values[field]))
+		if err != nil {
+			return "", "", fmt.Errorf("error writing multipart file: %w", err)
+		}
+		if _, err := part.Write(content); err != nil {
+			return "", "", fmt.Errorf("error writing multipart file: %w", err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return "", "", fmt.Errorf("error closing multipart body: %w", err)
+	}
+	return body.String(), writer.Boundary(), nil
+}
+
+// jsonEscape escapes a value for use inside a JSON string.
+func jsonEscape(value string) string {
+	var b bytes.Buffer
+	encoder := json.NewEncoder(&b)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(value)
+	escaped := strings.TrimSuffix(b.String(), "\n")
+	return escaped[1 : len(escaped)-1]
+}
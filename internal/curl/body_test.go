@@ -0,0 +1,210 @@
+package curl
+
+import (
+	"faast-go/internal/config"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEncodeBody(t *testing.T) {
+	tests := []struct {
+		name        string
+		yamlConfig  config.YamlConfig
+		permutation []string
+		want        Payload
+		wantErr     bool
+	}{
+		{
+			name: "Query string",
+			yamlConfig: config.YamlConfig{
+				Endpoint: "http://example.com/search?lang=en",
+				Method:   "get",
+				BodyType: "query",
+				Fields: []config.Field{
+					{Name: "q", Wordlist: "queries.txt"},
+					{Name: "page", Value: "1"},
+				},
+			},
+			permutation: []string{"a b&c"},
+			want: Payload{
+				Method: "GET",
+				URL:    "http://example.com/search?lang=en&q=a+b%26c&page=1",
+				Header: http.Header{},
+			},
+		},
+		{
+			name: "Form template",
+			yamlConfig: config.YamlConfig{
+				Endpoint: "http://example.com",
+				BodyType: "form",
+				Body:     "user={{user}}&submit=1",
+				Fields:   []config.Field{{Name: "user", Wordlist: "users.txt"}},
+			},
+			permutation: []string{"a&b"},
+			want: Payload{
+				Method: "POST",
+				URL:    "http://example.com",
+				Header: http.Header{"Content-Type": {"application/x-www-form-urlencoded"}},
+				Body:   "user=a%26b&submit=1",
+			},
+		},
+		{
+			name: "JSON with nested and typed fields",
+			yamlConfig: config.YamlConfig{
+				Endpoint: "http://example.com",
+				BodyType: "json",
+				Fields: []config.Field{
+					{Name: "user.name", Wordlist: "users.txt"},
+					{Name: "user.age", Wordlist: "ages.txt", Type: "int"},
+					{Name: "remember", Value: "true", Type: "bool"},
+					{Name: "score", Value: "1.5", Type: "float"},
+					{Name: "extra", Value: `{"a":[1,2]}`, Type: "json"},
+					{Name: "token", Type: "null"},
+				},
+			},
+			permutation: []string{"<admin>", "42"},
+			want: Payload{
+				Method: "POST",
+				URL:    "http://example.com",
+				Header: http.Header{"Content-Type": {"application/json"}},
+				Body:   `{"extra":{"a":[1,2]},"remember":true,"score":1.5,"token":null,"user":{"age":42,"name":"<admin>"}}`,
+			},
+		},
+		{
+			name: "JSON template",
+			yamlConfig: config.YamlConfig{
+				Endpoint: "http://example.com",
+				BodyType: "json",
+				Body:     `{"user": "{{user}}"}`,
+				Fields:   []config.Field{{Name: "user", Wordlist: "users.txt"}},
+			},
+			permutation: []string{`a"b\c`},
+			want: Payload{
+				Method: "POST",
+				URL:    "http://example.com",
+				Header: http.Header{"Content-Type": {"application/json"}},
+				Body:   `{"user": "a\"b\\c"}`,
+			},
+		},
+		{
+			name: "JSON value that does not match its type",
+			yamlConfig: config.YamlConfig{
+				Endpoint: "http://example.com",
+				BodyType: "json",
+				Fields:   []config.Field{{Name: "age", Wordlist: "ages.txt", Type: "int"}},
+			},
+			permutation: []string{"forty"},
+			wantErr:     true,
+		},
+		{
+			name: "JSON path conflict",
+			yamlConfig: config.YamlConfig{
+				Endpoint: "http://example.com",
+				BodyType: "json",
+				Fields: []config.Field{
+					{Name: "user", Value: "a"},
+					{Name: "user.name", Value: "b"},
+				},
+			},
+			permutation: []string{},
+			wantErr:     true,
+		},
+		{
+			name: "Raw body keeps the given content type",
+			yamlConfig: config.YamlConfig{
+				Endpoint: "http://example.com",
+				Method:   "PUT",
+				BodyType: "raw",
+				Headers:  []string{"Content-Type: text/xml"},
+				Body:     "<user>{{user}}</user>",
+				Fields:   []config.Field{{Name: "user", Wordlist: "users.txt"}},
+			},
+			permutation: []string{"a&b"},
+			want: Payload{
+				Method: "PUT",
+				URL:    "http://example.com",
+				Header: http.Header{"Content-Type": {"text/xml"}},
+				Body:   "<user>a&b</user>",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.yamlConfig.SetDefaults()
+			c, err := NewCurlConfig(&tt.yamlConfig)
+			if err != nil {
+				t.Fatalf("NewCurlConfig failed: %v", err)
+			}
+			got, err := c.ConstructPayload(tt.permutation)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ConstructPayload() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("ConstructPayload() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncodeBody_Multipart(t *testing.T) {
+	upload := filepath.Join(t.TempDir(), "shell.php")
+	if err := os.WriteFile(upload, []byte("<?php ?>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := NewCurlConfig(&config.YamlConfig{
+		Endpoint: "http://example.com/upload",
+		BodyType: "multipart",
+		Fields: []config.Field{
+			{Name: "name", Value: "avatar"},
+			{Name: "file", Wordlist: "uploads.txt", Type: "file"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewCurlConfig failed: %v", err)
+	}
+
+	payload, err := c.ConstructPayload([]string{upload})
+	if err != nil {
+		t.Fatalf("ConstructPayload failed: %v", err)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(payload.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/form-data" {
+		t.Fatalf("unexpected Content-Type %q", payload.Header.Get("Content-Type"))
+	}
+
+	reader := multipart.NewReader(strings.NewReader(payload.Body), params["boundary"])
+	got := map[string]string{}
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("NextPart failed: %v", err)
+		}
+		content, _ := io.ReadAll(part)
+		got[part.FormName()+":"+part.FileName()] = string(content)
+	}
+
+	want := map[string]string{
+		"name:":          "avatar",
+		"file:shell.php": "<?php ?>",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("multipart parts = %v, want %v", got, want)
+	}
+
+	if _, err := c.ConstructPayload([]string{filepath.Join(t.TempDir(), "missing")}); err == nil {
+		t.Error("ConstructPayload should fail when the file part does not exist")
+	}
+}
@@ -0,0 +1,127 @@
+package curl
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"math/big"
+	"strings"
+	"sync/atomic"
+	texttemplate "text/template"
+	"time"
+)
+
+// joined turns an encoder into a template function taking any number of
+// values, which are joined before being encoded.
+func joined(encode func(string) string) func(...string) string {
+	return func(values ...string) string {
+		return encode(strings.Join(values, ""))
+	}
+}
+
+// computeFuncs are the functions available to computed fields. counter is
+// shared by all computed fields of the run.
+func computeFuncs(counter *atomic.Int64) texttemplate.FuncMap {
+	return texttemplate.FuncMap{
+		"md5":         joined(namedEncoders["md5"]),
+		"sha1":        joined(namedEncoders["sha1"]),
+		"sha256":      joined(namedEncoders["sha256"]),
+		"base64":      joined(namedEncoders["base64"]),
+		"base64url":   joined(namedEncoders["base64url"]),
+		"hex":         joined(namedEncoders["hex"]),
+		"url":         joined(namedEncoders["url"]),
+		"html":        joined(namedEncoders["html-entity"]),
+		"json":        joined(namedEncoders["json-escape"]),
+		"upper":       strings.ToUpper,
+		"lower":       strings.ToLower,
+		"hmac":        hmacHex,
+		"uuid":        uuid,
+		"nonce":       nonce,
+		"random":      randomString,
+		"counter":     func() int64 { return counter.Add(1) },
+		"timestamp":   func() int64 { return time.Now().Unix() },
+		"timestampMs": func() int64 { return time.Now().UnixMilli() },
+		"now":         func(layout string) string { return time.Now().Format(layout) },
+	}
+}
+
+// hmacHex is the hex HMAC of message with key, using md5, sha1 or sha256.
+func hmacHex(algorithm, key, message string) (string, error) {
+	var h func() hash.Hash
+	switch algorithm {
+	case "md5":
+		h = md5.New
+	case "sha1":
+		h = sha1.New
+	case "sha256":
+		h = sha256.New
+	default:
+		return "", fmt.Errorf("unknown hmac algorithm %q", algorithm)
+	}
+	mac := hmac.New(h, []byte(key))
+	mac.Write([]byte(message))
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// uuid is a random version 4 UUID.
+func uuid() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// nonce is n random bytes as hex.
+func nonce(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+const alphanumeric = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// randomString is n random letters and digits.
+func randomString(n int) string {
+	b := make([]byte, n)
+	for i := range b {
+		index, _ := rand.Int(rand.Reader, big.NewInt(int64(len(alphanumeric))))
+		b[i] = alphanumeric[index.Int64()]
+	}
+	return string(b)
+}
+
+// compute renders the computed fields in field order. Templates see the
+// values of the other fields before their encoders, and the computed fields
+// above them.
+func (c *CurlConfig) compute(values []string) error {
+	data := make(map[string]string, len(values))
+	for i, name := range c.Fields {
+		data[name] = values[i]
+	}
+	for i, t := range c.computed {
+		if t == nil {
+			continue
+		}
+		var b strings.Builder
+		if err := t.Execute(&b, data); err != nil {
+			return fmt.Errorf("error computing field %s: %w", c.Fields[i], err)
+		}
+		values[i] = b.String()
+		data[c.Fields[i]] = values[i]
+	}
+	return nil
+}
+
+func parseCompute(name, text string, counter *atomic.Int64) (*texttemplate.Template, error) {
+	t, err := texttemplate.New(name).Funcs(computeFuncs(counter)).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid compute: %w", err)
+	}
+	return t, nil
+}
@@ -0,0 +1,90 @@
+package curl
+
+import (
+	"net/url"
+	"regexp"
+	"testing"
+
+	"faast-go/internal/config"
+)
+
+func TestCompute(t *testing.T) {
+	tests := []struct {
+		name    string
+		compute string
+		want    string
+		match   string
+		wantErr bool
+	}{
+		{name: "Hash of fields", compute: "{{ md5 .user .pass }}", want: "172eee54aa664e9dd0536b063796e54e"},
+		{name: "Basic auth", compute: `{{ base64 (printf "%s:%s" .user .pass) }}`, want: "YWRtaW46YWRtaW4xMjM="},
+		{name: "Text around", compute: "{{ upper .user }}-{{ .pass }}", want: "ADMIN-admin123"},
+		{
+			name:    "HMAC",
+			compute: `{{ hmac "sha256" "key" "The quick brown fox jumps over the lazy dog" }}`,
+			want:    "f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8",
+		},
+		{name: "Earlier computed field", compute: "{{ sha1 .first }}", want: "b521caa6e1db82e5a01c924a419870cb72b81635"},
+		{name: "UUID", compute: "{{ uuid }}", match: `^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`},
+		{name: "Nonce", compute: "{{ nonce 8 }}", match: `^[0-9a-f]{16}$`},
+		{name: "Random", compute: "{{ random 12 }}", match: `^[a-zA-Z0-9]{12}$`},
+		{name: "Timestamp", compute: "{{ timestamp }}", match: `^[0-9]{10}$`},
+		{name: "Now", compute: `{{ now "2006" }}`, match: `^[0-9]{4}$`},
+		{name: "Unknown function", compute: "{{ rot13 .user }}", wantErr: true},
+		{name: "Unknown field", compute: "{{ .nope }}", wantErr: true},
+		{name: "Unknown hmac algorithm", compute: `{{ hmac "sha512" "k" "m" }}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := NewCurlConfig(&config.YamlConfig{
+				Endpoint: "http://example.com/?v={{value}}&user={{user}}&pass={{pass}}",
+				BodyType: "raw",
+				Fields: []config.Field{
+					{Name: "user", Wordlist: "users.txt"},
+					{Name: "pass", Wordlist: "passwords.txt"},
+					{Name: "first", Compute: "{{ upper .user }}"},
+					{Name: "value", Compute: tt.compute},
+				},
+			})
+			if err == nil {
+				var payload *Payload
+				if payload, err = c.ConstructPayload([]string{"admin", "admin123"}); err == nil {
+					u, _ := url.Parse(payload.URL)
+					got := u.Query().Get("v")
+					if tt.match == "" && got != tt.want {
+						t.Errorf("Got %q, want %q", got, tt.want)
+					}
+					if tt.match != "" && !regexp.MustCompile(tt.match).MatchString(got) {
+						t.Errorf("Got %q, want a match of %s", got, tt.match)
+					}
+				}
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCompute_Counter(t *testing.T) {
+	c, err := NewCurlConfig(&config.YamlConfig{
+		Endpoint: "http://example.com/{{n}}",
+		Fields: []config.Field{
+			{Name: "id", Wordlist: "ids.txt"},
+			{Name: "n", Compute: "{{ counter }}", Encoders: []string{"base64"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewCurlConfig failed: %v", err)
+	}
+	for _, want := range []string{"MQ==", "Mg==", "Mw=="} {
+		payload, err := c.ConstructPayload([]string{"1"})
+		if err != nil {
+			t.Fatalf("ConstructPayload failed: %v", err)
+		}
+		if payload.URL != "http://example.com/"+want {
+			t.Errorf("URL = %s, want http://example.com/%s", payload.URL, want)
+		}
+	}
+}
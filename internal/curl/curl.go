@@ -2,30 +2,83 @@ package curl
 
 import (
 	"context"
+	"errors"
 	"faast-go/internal/config"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"strings"
+	"sync/atomic"
+	texttemplate "text/template"
 	"time"
 
 	"golang.org/x/time/rate"
 )
 
 type CurlConfig struct {
-	ValidateType string
-	SizeDefault  int
-	CodeDefault  int
-	Cookies      []http.Cookie
-	URL          string
-	RateLimiter  *rate.Limiter
-	UserAgent    string
-	Client       *http.Client
-	Fields       []string
-	StaticValues []string
+	Cookies     []http.Cookie
+	Headers     []string
+	Method      string
+	URL         string
+	BodyType    string
+	Body        string
+	RateLimiter *rate.Limiter
+	Throttle    *Throttle
+	UserAgent   string
+	Client      *http.Client
+	Fields      []string
+
+	// staticValues holds the value of every static field, and sources the
+	// permutation index of every list field (-1 for static fields). computed
+	// holds the template of every computed field and encoders the encoder
+	// chain of every field, nil when a field has none.
+	staticValues []string
+	sources      []int
+	numSources   int
+	fieldTypes   []string
+	computed     []*texttemplate.Template
+	counter      atomic.Int64
+	encoders     []func(string) string
+
+	// userAgents replaces UserAgent when a list of agents is given, and
+	// removeHeaders are the canonical names of the headers never sent.
+	userAgents    *userAgents
+	removeHeaders []string
+	// proxies is the proxy pool requests are rotated over, if any.
+	proxies *proxyPool
+
+	// The endpoint is split at the query string, as values are escaped
+	// differently in the path and in the query.
+	pathTemplate    *template
+	queryTemplate   *template
+	bodyTemplate    *template
+	headerTemplates []namedTemplate
+	cookieTemplates []namedTemplate
+	// bodyFields are the fields not placed by any marker, which are encoded
+	// according to BodyType when no body template is given.
+	bodyFields []int
+}
+
+type namedTemplate struct {
+	name  string
+	value *template
+}
+
+// Payload is a single rendered request, ready to be sent with SendCurl.
+type Payload struct {
+	Method  string
+	URL     string
+	Header  http.Header
+	Cookies []http.Cookie
+	Body    string
+
+	// userAgent is the User-Agent the payload was last sent with.
+	userAgent string
 }
 
+// NewCurlConfig builds the requests of a config whose defaults are set, as
+// they are by config.LoadConfig.
 func NewCurlConfig(config *config.YamlConfig) (*CurlConfig, error) {
 	cookies := make([]http.Cookie, 0, len(config.Cookies))
 	for _, cookieStr := range config.Cookies {
@@ -37,78 +90,396 @@ func NewCurlConfig(config *config.YamlConfig) (*CurlConfig, error) {
 	}
 
 	var rateLimiter *rate.Limiter
+	var throttle *Throttle
 	if config.RateLimit > 0 {
-		rateLimiter = rate.NewLimiter(rate.Limit(config.RateLimit), int(config.RateLimit))
-	}
-
-	return &CurlConfig{
-		ValidateType: config.ValidateType,
-		SizeDefault:  config.SizeDefault,
-		CodeDefault:  config.CodeDefault,
-		Cookies:      cookies,
-		URL:          config.Endpoint,
-		RateLimiter:  rateLimiter,
-		// this will be a variable in the future
-		UserAgent:    "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.0.0 Safari/537.36",
-		Client:       &http.Client{Timeout: time.Duration(config.Timeout) * time.Second},
-		Fields:       config.Fields,
-		StaticValues: config.StaticValues,
-	}, nil
-}
+		rateLimiter = newLimiter(config.RateLimit)
+		throttle = newThrottle(rateLimiter, config.Throttle)
+		if !throttle.enabled() {
+			throttle = nil
+		}
+	}
 
-func (c *CurlConfig) ValidateResponse(res *http.Response) bool {
-	switch c.ValidateType {
-	case "size":
-		return res.ContentLength == int64(c.SizeDefault)
-	case "code":
-		return res.StatusCode == c.CodeDefault
-	default:
-		fmt.Printf("Warning: invalid validate type '%s'. Defaulting to true.\n", c.ValidateType)
-		return true
+	c := &CurlConfig{
+		Cookies:     cookies,
+		Headers:     config.Headers,
+		Method:      strings.ToUpper(config.Method),
+		URL:         config.Endpoint,
+		BodyType:    config.BodyType,
+		Body:        config.Body,
+		RateLimiter: rateLimiter,
+		Throttle:    throttle,
+		// sent unless userAgents or a header give another
+		UserAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.0.0 Safari/537.36",
 	}
+
+	tlsConfig, err := newTLSConfig(config.TLS)
+	if err != nil {
+		return nil, err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	transport.DialContext = newDialer(config)
+	for _, name := range config.RemoveHeaders {
+		name = http.CanonicalHeaderKey(name)
+		c.removeHeaders = append(c.removeHeaders, name)
+		if name == "Accept-Encoding" {
+			// Go only asks for gzip itself when compression is enabled.
+			transport.DisableCompression = true
+		}
+	}
+	if err := c.setProxy(transport, config); err != nil {
+		return nil, err
+	}
+	c.Client = &http.Client{Timeout: time.Duration(config.Timeout) * time.Second, Transport: transport}
+
+	agents, err := loadUserAgents(config.UserAgents, config.UserAgentFile)
+	if err != nil {
+		return nil, err
+	}
+	if len(agents) > 0 {
+		c.userAgents = &userAgents{agents: agents, rotation: config.UserAgentRotation}
+	}
+
+	if config.Request != "" {
+		if err := c.applyRequestFile(config.Request, config.Scheme); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.bindFields(config); err != nil {
+		return nil, err
+	}
+	if err := c.compileTemplates(); err != nil {
+		return nil, err
+	}
+	if c.BodyType == "raw" {
+		if err := c.checkPlaced(config.Fields); err != nil {
+			return nil, err
+		}
+	}
+
+	return c, nil
 }
 
-func (c *CurlConfig) SendCurl(ctx context.Context, body io.Reader) (*http.Response, error) {
-	req, err := http.NewRequestWithContext(ctx, "POST", c.URL, body)
+// applyRequestFile takes the method, URL, headers and body from a raw request
+// file. A method or body set in the config takes precedence, and configured
+// headers are sent after the ones from the file. Unless the body is sent
+// raw, it is encoded again, so the Content-Type of the file is dropped for
+// the one of the body type.
+func (c *CurlConfig) applyRequestFile(filename string, scheme string) error {
+	request, err := loadRequestFile(filename, scheme)
 	if err != nil {
-		return nil, fmt.Errorf("error creating request: %w", err)
+		return err
 	}
+	if request.body != "" && c.Body == "" && (c.BodyType == "multipart" || c.BodyType == "query") {
+		return fmt.Errorf("the body of request file %s cannot be used with bodyType %s", filename, c.BodyType)
+	}
+	if c.Method == "" {
+		c.Method = strings.ToUpper(request.method)
+	}
+	c.URL = request.url
+	var headers []string
+	for _, header := range request.headers {
+		if c.BodyType != "raw" && strings.HasPrefix(header, "Content-Type:") {
+			continue
+		}
+		headers = append(headers, header)
+	}
+	c.Headers = append(headers, c.Headers...)
+	if c.Body == "" {
+		c.Body = request.body
+	}
+	return nil
+}
 
-	for _, cookie := range c.Cookies {
-		req.AddCookie(&cookie)
+// bindFields binds every field to the permutation value of its source, or
+// to its static value.
+func (c *CurlConfig) bindFields(settings *config.YamlConfig) error {
+	fields := settings.Fields
+	c.Fields = make([]string, len(fields))
+	c.staticValues = make([]string, len(fields))
+	c.fieldTypes = make([]string, len(fields))
+	c.encoders = make([]func(string) string, len(fields))
+	c.computed = make([]*texttemplate.Template, len(fields))
+	for i, field := range fields {
+		if field.Compute != "" {
+			t, err := parseCompute(field.Name, field.Compute, &c.counter)
+			if err != nil {
+				return fmt.Errorf("field %s: %w", field.Name, err)
+			}
+			c.computed[i] = t
+		}
+		if len(field.Encoders) > 0 {
+			encoder, err := encoderChain(field.Encoders)
+			if err != nil {
+				return fmt.Errorf("field %s: %w", field.Name, err)
+			}
+			c.encoders[i] = encoder
+		}
+		c.Fields[i] = field.Name
+		c.fieldTypes[i] = field.Type
+		c.staticValues[i] = settings.Resolve(field).Value
 	}
-	req.Header.Set("User-Agent", c.UserAgent)
+	c.sources = settings.FieldSources()
+	c.numSources = len(settings.ListFields())
+	return nil
+}
 
-	if c.RateLimiter != nil {
-		if err := c.RateLimiter.Wait(ctx); err != nil {
-			return nil, fmt.Errorf("rate limiter wait cancelled: %w", err)
+func (c *CurlConfig) compileTemplates() error {
+	fieldIndex := make(map[string]int, len(c.Fields))
+	for i, name := range c.Fields {
+		fieldIndex[name] = i
+	}
+
+	path, query, found := strings.Cut(c.URL, "?")
+	if found {
+		query = "?" + query
+	}
+	var err error
+	if c.pathTemplate, err = parseTemplate(path, fieldIndex); err != nil {
+		return fmt.Errorf("invalid endpoint: %w", err)
+	}
+	if c.queryTemplate, err = parseTemplate(query, fieldIndex); err != nil {
+		return fmt.Errorf("invalid endpoint: %w", err)
+	}
+	if c.Body != "" {
+		if c.bodyTemplate, err = parseTemplate(c.Body, fieldIndex); err != nil {
+			return fmt.Errorf("invalid body: %w", err)
+		}
+	}
+	for _, header := range c.Headers {
+		parts := strings.SplitN(header, ":", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("invalid header format: %s", header)
+		}
+		value, err := parseTemplate(strings.TrimSpace(parts[1]), fieldIndex)
+		if err != nil {
+			return fmt.Errorf("invalid header %s: %w", parts[0], err)
+		}
+		c.headerTemplates = append(c.headerTemplates, namedTemplate{name: strings.TrimSpace(parts[0]), value: value})
+	}
+	for _, cookie := range c.Cookies {
+		value, err := parseTemplate(cookie.Value, fieldIndex)
+		if err != nil {
+			return fmt.Errorf("invalid cookie %s: %w", cookie.Name, err)
 		}
+		c.cookieTemplates = append(c.cookieTemplates, namedTemplate{name: cookie.Name, value: value})
+	}
+	for i := range c.Fields {
+		if !c.placed(i) {
+			c.bodyFields = append(c.bodyFields, i)
+		}
+	}
+	return nil
+}
+
+// ErrNotSent is returned by SendCurl when ctx is cancelled while the request
+// waits for the rate limiter or a throttle pause.
+var ErrNotSent = errors.New("request not sent")
+
+// SendCurl waits for the rate limiter, then sends the payload. Cancelling ctx
+// only stops the wait: a request that was sent is finished, so its response
+// is not lost.
+func (c *CurlConfig) SendCurl(ctx context.Context, payload *Payload) (*Response, error) {
+	if c.proxies != nil {
+		ctx = context.WithValue(ctx, proxyKey{}, &proxyChoice{})
+	}
+	payload.userAgent = c.userAgent(ctx)
+	req, err := c.newRequest(context.WithoutCancel(ctx), payload)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.Throttle != nil {
+		err = c.Throttle.Wait(ctx)
+	} else if c.RateLimiter != nil {
+		err = c.RateLimiter.Wait(ctx)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrNotSent, err)
 	}
 
+	start := time.Now()
 	resp, err := c.Client.Do(req)
+	if c.proxies != nil {
+		c.proxies.report(ctx, err)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("error from response: %w", err)
 	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading response body: %w", err)
+	}
+
+	location := resp.Header.Get("Location")
+	if resp.Request != nil && resp.Request.URL.String() != req.URL.String() {
+		location = resp.Request.URL.String()
+	}
 
-	return resp, nil
+	response := &Response{
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+		Body:       body,
+		Duration:   time.Since(start),
+		Location:   location,
+	}
+	if c.Throttle != nil && c.Throttle.Throttled(response) {
+		c.Throttle.backOff(response)
+		return response, fmt.Errorf("%w (status %d)", ErrThrottled, response.StatusCode)
+	}
+	return response, nil
 }
 
-func (c *CurlConfig) ConstructPayload(permutation []string) (*strings.Reader, error) {
-	if len(c.Fields) != (len(permutation) + len(c.StaticValues)) {
-		return nil, fmt.Errorf("error: length of permutation and values are not equal")
+// newRequest builds the HTTP request of a payload, with the User-Agent it
+// was given when it was sent.
+func (c *CurlConfig) newRequest(ctx context.Context, payload *Payload) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, payload.Method, payload.URL, strings.NewReader(payload.Body))
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
 	}
 
-	var payload strings.Builder
-	for i, field := range c.Fields {
-		if i > 0 {
-			payload.WriteString("&")
+	req.Header.Set("User-Agent", payload.userAgent)
+	for name, values := range payload.Header {
+		req.Header[name] = values
+	}
+	for _, name := range c.removeHeaders {
+		req.Header.Del(name)
+		if name == "User-Agent" {
+			// Go sends its own User-Agent when there is none, but not an
+			// empty one.
+			req.Header.Set(name, "")
 		}
-		if i < len(permutation) {
-			payload.WriteString(url.QueryEscape(field) + "=" + url.QueryEscape(permutation[i]))
+	}
+	for _, cookie := range payload.Cookies {
+		req.AddCookie(&cookie)
+	}
+	return req, nil
+}
+
+// userAgent is the User-Agent of a request, unless a header sets its own.
+func (c *CurlConfig) userAgent(ctx context.Context) string {
+	if c.userAgents == nil {
+		return c.UserAgent
+	}
+	return c.userAgents.pick(ctx)
+}
+
+// NumSources is the number of values a permutation must have.
+func (c *CurlConfig) NumSources() int {
+	return c.numSources
+}
+
+// SourceTypes is the type of every permutation value: the type of the first
+// field placing it that has one.
+func (c *CurlConfig) SourceTypes() []string {
+	types := make([]string, c.numSources)
+	for i, source := range c.sources {
+		if source >= 0 && types[source] == "" {
+			types[source] = c.fieldTypes[i]
+		}
+	}
+	return types
+}
+
+// ConstructPayload renders the request for a single permutation. Fields that
+// are not placed anywhere with a marker are encoded according to BodyType.
+func (c *CurlConfig) ConstructPayload(permutation []string) (*Payload, error) {
+	if len(permutation) != c.numSources {
+		return nil, fmt.Errorf("error: length of permutation and values are not equal")
+	}
+
+	values := make([]string, len(c.Fields))
+	for i, source := range c.sources {
+		if source < 0 {
+			values[i] = c.staticValues[i]
 		} else {
-			payload.WriteString(url.QueryEscape(field) + "=" + url.QueryEscape(c.StaticValues[i-len(permutation)]))
+			values[i] = permutation[source]
+		}
+	}
+	if err := c.compute(values); err != nil {
+		return nil, err
+	}
+	for i, encode := range c.encoders {
+		if encode != nil {
+			values[i] = encode(values[i])
+		}
+	}
+
+	payload := &Payload{
+		Method: c.Method,
+		URL:    c.pathTemplate.render(values, c.escaper(url.PathEscape)) + c.queryTemplate.render(values, c.escaper(url.QueryEscape)),
+		Header: make(http.Header),
+	}
+	for _, header := range c.headerTemplates {
+		payload.Header.Add(header.name, header.value.render(values, c.escaper(nil)))
+	}
+	for _, cookie := range c.cookieTemplates {
+		payload.Cookies = append(payload.Cookies, http.Cookie{Name: cookie.name, Value: cookie.value.render(values, c.escaper(nil))})
+	}
+
+	if err := c.encodeBody(payload, values); err != nil {
+		return nil, err
+	}
+
+	return payload, nil
+}
+
+// placed reports whether a field is referenced by a marker anywhere in the
+// request template.
+// checkPlaced fails when the values of a list are not placed anywhere, by a
+// marker or through a computed field that is. A raw body does not encode the
+// other fields, so every request would be the same.
+func (c *CurlConfig) checkPlaced(fields []config.Field) error {
+	index := make(map[string]int, len(c.Fields))
+	used := make([]bool, len(c.Fields))
+	for i, name := range c.Fields {
+		index[name] = i
+		used[i] = c.placed(i)
+	}
+	for changed := true; changed; {
+		changed = false
+		for i, field := range fields {
+			if !used[i] || field.Compute == "" {
+				continue
+			}
+			names, _ := field.ComputeFields()
+			for _, name := range names {
+				if j, ok := index[name]; ok && !used[j] {
+					used[j], changed = true, true
+				}
+			}
 		}
 	}
 
-	return strings.NewReader(payload.String()), nil
+	sourceUsed := make([]bool, c.numSources)
+	for i, source := range c.sources {
+		if source >= 0 && used[i] {
+			sourceUsed[source] = true
+		}
+	}
+	for i, source := range c.sources {
+		if source >= 0 && !sourceUsed[source] {
+			return fmt.Errorf("field %s is not placed anywhere, add a {{%s}} marker to the raw body", c.Fields[i], c.Fields[i])
+		}
+	}
+	return nil
+}
+
+func (c *CurlConfig) placed(field int) bool {
+	if c.pathTemplate.uses(field) || c.queryTemplate.uses(field) || (c.bodyTemplate != nil && c.bodyTemplate.uses(field)) {
+		return true
+	}
+	for _, header := range c.headerTemplates {
+		if header.value.uses(field) {
+			return true
+		}
+	}
+	for _, cookie := range c.cookieTemplates {
+		if cookie.value.uses(field) {
+			return true
+		}
+	}
+	return false
 }
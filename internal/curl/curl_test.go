@@ -3,10 +3,9 @@ package curl
 import (
 	"context"
 	"faast-go/internal/config"
-	"io"
 	"net/http"
 	"net/http/httptest"
-	"strings"
+	"reflect"
 	"testing"
 	"time"
 )
@@ -20,8 +19,10 @@ func TestNewCurlConfig(t *testing.T) {
 		Endpoint:     "http://example.com",
 		RateLimit:    10,
 		Timeout:      5,
-		Fields:       []string{"field1", "field2"},
-		StaticValues: []string{"static1"},
+		Fields: []config.Field{
+			{Name: "field1", Wordlist: "wordlist1.txt"},
+			{Name: "field2", Value: "static1"},
+		},
 	}
 
 	curlConfig, err := NewCurlConfig(yamlConfig)
@@ -30,15 +31,6 @@ func TestNewCurlConfig(t *testing.T) {
 	}
 
 	// Check if the CurlConfig fields are set correctly
-	if curlConfig.ValidateType != yamlConfig.ValidateType {
-		t.Errorf("ValidateType mismatch. Got %s, want %s", curlConfig.ValidateType, yamlConfig.ValidateType)
-	}
-	if curlConfig.SizeDefault != yamlConfig.SizeDefault {
-		t.Errorf("SizeDefault mismatch. Got %d, want %d", curlConfig.SizeDefault, yamlConfig.SizeDefault)
-	}
-	if curlConfig.CodeDefault != yamlConfig.CodeDefault {
-		t.Errorf("CodeDefault mismatch. Got %d, want %d", curlConfig.CodeDefault, yamlConfig.CodeDefault)
-	}
 	if len(curlConfig.Cookies) != len(yamlConfig.Cookies) {
 		t.Errorf("Cookies length mismatch. Got %d, want %d", len(curlConfig.Cookies), len(yamlConfig.Cookies))
 	}
@@ -55,64 +47,21 @@ func TestNewCurlConfig(t *testing.T) {
 	if err == nil {
 		t.Error("NewCurlConfig should have returned an error for invalid cookie format")
 	}
-}
 
-func TestValidateResponse(t *testing.T) {
-	tests := []struct {
-		name         string
-		validateType string
-		sizeDefault  int
-		codeDefault  int
-		response     *http.Response
-		want         bool
-	}{
-		{
-			name:         "Validate Size Success",
-			validateType: "size",
-			sizeDefault:  100,
-			response:     &http.Response{ContentLength: 100},
-			want:         true,
-		},
-		{
-			name:         "Validate Size Failure",
-			validateType: "size",
-			sizeDefault:  100,
-			response:     &http.Response{ContentLength: 200},
-			want:         false,
-		},
-		{
-			name:         "Validate Code Success",
-			validateType: "code",
-			codeDefault:  404,
-			response:     &http.Response{StatusCode: 404},
-			want:         true,
-		},
-		{
-			name:         "Validate Code Failure",
-			validateType: "code",
-			codeDefault:  404,
-			response:     &http.Response{StatusCode: 200},
-			want:         false,
-		},
-		{
-			name:         "Invalid Validate Type",
-			validateType: "invalid",
-			response:     &http.Response{},
-			want:         true,
-		},
+	// Test marker referring to an unknown field
+	yamlConfig.Cookies = nil
+	yamlConfig.Endpoint = "http://example.com/{{missing}}"
+	_, err = NewCurlConfig(yamlConfig)
+	if err == nil {
+		t.Error("NewCurlConfig should have returned an error for an unknown marker")
 	}
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			c := &CurlConfig{
-				ValidateType: tt.validateType,
-				SizeDefault:  tt.sizeDefault,
-				CodeDefault:  tt.codeDefault,
-			}
-			if got := c.ValidateResponse(tt.response); got != tt.want {
-				t.Errorf("ValidateResponse() = %v, want %v", got, tt.want)
-			}
-		})
+	// Test invalid header format
+	yamlConfig.Endpoint = "http://example.com"
+	yamlConfig.Headers = []string{"no-colon"}
+	_, err = NewCurlConfig(yamlConfig)
+	if err == nil {
+		t.Error("NewCurlConfig should have returned an error for invalid header format")
 	}
 }
 
@@ -135,77 +84,339 @@ func TestSendCurl(t *testing.T) {
 	defer server.Close()
 
 	c := &CurlConfig{
+		Client:    &http.Client{},
+		UserAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.0.0 Safari/537.36",
+	}
+	payload := &Payload{
+		Method: "POST",
 		URL:    server.URL,
-		Client: &http.Client{},
+		Header: http.Header{},
 		Cookies: []http.Cookie{
 			{Name: "session", Value: "abc123"},
 		},
-		UserAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.0.0 Safari/537.36",
+		Body: "test body",
 	}
 
-	resp, err := c.SendCurl(context.Background(), strings.NewReader("test body"))
+	resp, err := c.SendCurl(context.Background(), payload)
 	if err != nil {
 		t.Fatalf("SendCurl failed: %v", err)
 	}
-	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		t.Errorf("Unexpected status code. Got %d, want %d", resp.StatusCode, http.StatusOK)
 	}
 
-	body, err := io.ReadAll(resp.Body)
+	if string(resp.Body) != "Test response" {
+		t.Errorf("Unexpected response body. Got %s, want %s", string(resp.Body), "Test response")
+	}
+
+	if resp.Duration <= 0 {
+		t.Errorf("Expected a positive duration, got %v", resp.Duration)
+	}
+}
+
+func TestSendCurl_Headers(t *testing.T) {
+	var got []http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.Header)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name   string
+		config config.YamlConfig
+		check  func(t *testing.T, headers []http.Header)
+	}{
+		{
+			name: "Templated headers and rotating user agents",
+			config: config.YamlConfig{
+				Headers:           []string{"X-Requested-With: XMLHttpRequest", "X-Api-Key: {{key}}"},
+				UserAgents:        []string{"agent-a", "agent-b"},
+				UserAgentRotation: "request",
+			},
+			check: func(t *testing.T, headers []http.Header) {
+				for i, want := range []string{"agent-a", "agent-b"} {
+					if ua := headers[i].Get("User-Agent"); ua != want {
+						t.Errorf("request %d User-Agent = %q, want %q", i, ua, want)
+					}
+					if headers[i].Get("X-Requested-With") != "XMLHttpRequest" || headers[i].Get("X-Api-Key") != "k1" {
+						t.Errorf("request %d headers = %v", i, headers[i])
+					}
+				}
+			},
+		},
+		{
+			name: "Header overrides user agents",
+			config: config.YamlConfig{
+				Headers:    []string{"User-Agent: scanner {{key}}"},
+				UserAgents: []string{"agent-a"},
+			},
+			check: func(t *testing.T, headers []http.Header) {
+				if ua := headers[0].Get("User-Agent"); ua != "scanner k1" {
+					t.Errorf("User-Agent = %q, want %q", ua, "scanner k1")
+				}
+			},
+		},
+		{
+			name: "Removed headers",
+			config: config.YamlConfig{
+				Headers:       []string{"X-Debug: 1"},
+				RemoveHeaders: []string{"user-agent", "Accept-Encoding", "x-debug"},
+			},
+			check: func(t *testing.T, headers []http.Header) {
+				for _, name := range []string{"User-Agent", "Accept-Encoding", "X-Debug"} {
+					if values, ok := headers[0][name]; ok {
+						t.Errorf("%s = %q, want it removed", name, values)
+					}
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got = nil
+			tt.config.Endpoint = server.URL
+			tt.config.Fields = []config.Field{{Name: "key", Value: "k1"}}
+			c, err := NewCurlConfig(&tt.config)
+			if err != nil {
+				t.Fatalf("NewCurlConfig failed: %v", err)
+			}
+			for i := 0; i < 2; i++ {
+				payload, err := c.ConstructPayload(nil)
+				if err != nil {
+					t.Fatalf("ConstructPayload failed: %v", err)
+				}
+				if _, err := c.SendCurl(context.Background(), payload); err != nil {
+					t.Fatalf("SendCurl failed: %v", err)
+				}
+			}
+			tt.check(t, got)
+		})
+	}
+}
+
+func TestSendCurl_Location(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/old" {
+			http.Redirect(w, r, "/new", http.StatusFound)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	c := &CurlConfig{Client: &http.Client{}}
+	resp, err := c.SendCurl(context.Background(), &Payload{Method: "GET", URL: server.URL + "/old", Header: http.Header{}})
 	if err != nil {
-		t.Fatalf("Failed to read response body: %v", err)
+		t.Fatalf("SendCurl failed: %v", err)
+	}
+	if resp.Location != server.URL+"/new" {
+		t.Errorf("Location = %q, want %q", resp.Location, server.URL+"/new")
 	}
 
-	if string(body) != "Test response" {
-		t.Errorf("Unexpected response body. Got %s, want %s", string(body), "Test response")
+	resp, err = c.SendCurl(context.Background(), &Payload{Method: "GET", URL: server.URL + "/new", Header: http.Header{}})
+	if err != nil {
+		t.Fatalf("SendCurl failed: %v", err)
+	}
+	if resp.Location != "" {
+		t.Errorf("Location = %q, want empty", resp.Location)
 	}
 }
 
 func TestConstructPayload(t *testing.T) {
 	tests := []struct {
-		name         string
-		fields       []string
-		staticValues []string
-		permutation  []string
-		want         string
-		wantErr      bool
+		name        string
+		yamlConfig  config.YamlConfig
+		permutation []string
+		want        Payload
+		wantErr     bool
 	}{
 		{
-			name:         "Valid Payload",
-			fields:       []string{"field1", "field2", "field3"},
-			staticValues: []string{"static1"},
-			permutation:  []string{"value1", "value2"},
-			want:         "field1=value1&field2=value2&field3=static1",
-			wantErr:      false,
+			name: "Valid Payload",
+			yamlConfig: config.YamlConfig{
+				Endpoint: "http://example.com",
+				Fields: []config.Field{
+					{Name: "field1", Wordlist: "wordlist1.txt"},
+					{Name: "field2", Wordlist: "wordlist2.txt"},
+					{Name: "field3", Value: "static1"},
+				},
+			},
+			permutation: []string{"value1", "value2"},
+			want: Payload{
+				Method: "POST",
+				URL:    "http://example.com",
+				Header: http.Header{"Content-Type": {"application/x-www-form-urlencoded"}},
+				Body:   "field1=value1&field2=value2&field3=static1",
+			},
+		},
+		{
+			name: "Path and query markers",
+			yamlConfig: config.YamlConfig{
+				Endpoint: "http://example.com/files/{{name}}?path={{name}}",
+				Method:   "GET",
+				BodyType: "query",
+				Fields:   []config.Field{{Name: "name", Wordlist: "names.txt"}},
+			},
+			permutation: []string{"my file/a+b?"},
+			want: Payload{
+				Method: "GET",
+				URL:    "http://example.com/files/my%20file%2Fa+b%3F?path=my+file%2Fa%2Bb%3F",
+				Header: http.Header{},
+			},
+		},
+		{
+			name: "Markers",
+			yamlConfig: config.YamlConfig{
+				Endpoint: "http://example.com/users/{{id}}?q={{ query }}",
+				Headers:  []string{"X-Api-Key: {{key}}"},
+				Cookies:  []string{"session={{session}}"},
+				Body:     `{"id": "{{id}}"}`,
+				Fields: []config.Field{
+					{Name: "key", Value: "abc"},
+					{Name: "id", Wordlist: "ids.txt"},
+					{Name: "query", Wordlist: "queries.txt"},
+					{Name: "session", Wordlist: "sessions.txt"},
+				},
+			},
+			permutation: []string{"1 2", "a&b", "s1"},
+			want: Payload{
+				Method:  "POST",
+				URL:     "http://example.com/users/1%202?q=a%26b",
+				Header:  http.Header{"X-Api-Key": {"abc"}},
+				Cookies: []http.Cookie{{Name: "session", Value: "s1"}},
+				Body:    `{"id": "1 2"}`,
+			},
+		},
+		{
+			name: "Unplaced fields go in the form body",
+			yamlConfig: config.YamlConfig{
+				Endpoint: "http://example.com/{{user}}",
+				Fields: []config.Field{
+					{Name: "user", Wordlist: "users.txt"},
+					{Name: "pass word", Wordlist: "passwords.txt"},
+				},
+			},
+			permutation: []string{"admin", "p&ss"},
+			want: Payload{
+				Method: "POST",
+				URL:    "http://example.com/admin",
+				Header: http.Header{"Content-Type": {"application/x-www-form-urlencoded"}},
+				Body:   "pass+word=p%26ss",
+			},
+		},
+		{
+			name: "Encoders",
+			yamlConfig: config.YamlConfig{
+				Endpoint: "http://example.com/{{token}}?raw={{raw}}",
+				Headers:  []string{"Authorization: Basic {{creds}}"},
+				Fields: []config.Field{
+					{Name: "token", Wordlist: "tokens.txt", Encoders: []string{"base64", "url"}},
+					{Name: "raw", Value: "a/b?c", Encoders: []string{"none"}},
+					{Name: "creds", Value: "admin:admin", Encoders: []string{"base64"}},
+					{Name: "hash", Wordlist: "passwords.txt", Encoders: []string{"md5"}},
+					{Name: "plain", Value: "a&b"},
+				},
+			},
+			permutation: []string{"??>", "password"},
+			want: Payload{
+				Method: "POST",
+				URL:    "http://example.com/Pz8%2B?raw=a/b?c",
+				Header: http.Header{
+					"Authorization": {"Basic YWRtaW46YWRtaW4="},
+					"Content-Type":  {"application/x-www-form-urlencoded"},
+				},
+				Body: "hash=5f4dcc3b5aa765d61d8327deb882cf99&plain=a%26b",
+			},
+		},
+		{
+			name: "Refs and repeated wordlists",
+			yamlConfig: config.YamlConfig{
+				Endpoint: "http://example.com/{{user}}",
+				Fields: []config.Field{
+					{Name: "token", Value: "t1"},
+					{Name: "user", Wordlist: "users.txt"},
+					{Name: "login", Wordlist: "users.txt"},
+					{Name: "pass", Wordlist: "passwords.txt"},
+					{Name: "hash", Ref: "pass", Encoders: []string{"md5"}},
+					{Name: "csrf", Ref: "token"},
+				},
+			},
+			permutation: []string{"admin", "root", "password"},
+			want: Payload{
+				Method: "POST",
+				URL:    "http://example.com/admin",
+				Header: http.Header{"Content-Type": {"application/x-www-form-urlencoded"}},
+				Body:   "token=t1&login=root&pass=password&hash=5f4dcc3b5aa765d61d8327deb882cf99&csrf=t1",
+			},
 		},
 		{
-			name:         "Mismatched Lengths",
-			fields:       []string{"field1", "field2"},
-			staticValues: []string{"static1"},
-			permutation:  []string{"value1", "value2"},
-			want:         "",
-			wantErr:      true,
+			name: "Unknown encoder",
+			yamlConfig: config.YamlConfig{
+				Endpoint: "http://example.com",
+				Fields:   []config.Field{{Name: "field1", Wordlist: "wordlist1.txt", Encoders: []string{"rot13"}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Raw body through a computed field",
+			yamlConfig: config.YamlConfig{
+				Endpoint: "http://example.com/login",
+				BodyType: "raw",
+				Body:     "token={{token}}",
+				Fields: []config.Field{
+					{Name: "user", Wordlist: "users.txt"},
+					{Name: "token", Compute: "{{ upper .user }}"},
+				},
+			},
+			permutation: []string{"admin"},
+			want: Payload{
+				Method: "POST",
+				URL:    "http://example.com/login",
+				Header: http.Header{},
+				Body:   "token=ADMIN",
+			},
+		},
+		{
+			name: "Raw body without a list field",
+			yamlConfig: config.YamlConfig{
+				Endpoint: "http://example.com/login",
+				BodyType: "raw",
+				Body:     "user=admin",
+				Fields: []config.Field{
+					{Name: "user", Wordlist: "users.txt"},
+					{Name: "token", Compute: "{{ upper .user }}"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Mismatched Lengths",
+			yamlConfig: config.YamlConfig{
+				Endpoint: "http://example.com",
+				Fields: []config.Field{
+					{Name: "field1", Wordlist: "wordlist1.txt"},
+					{Name: "field2", Value: "static1"},
+				},
+			},
+			permutation: []string{"value1", "value2"},
+			wantErr:     true,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			c := &CurlConfig{
-				Fields:       tt.fields,
-				StaticValues: tt.staticValues,
+			tt.yamlConfig.SetDefaults()
+			c, err := NewCurlConfig(&tt.yamlConfig)
+			if err == nil {
+				var got *Payload
+				got, err = c.ConstructPayload(tt.permutation)
+				if err == nil && !reflect.DeepEqual(*got, tt.want) {
+					t.Errorf("ConstructPayload() = %+v, want %+v", *got, tt.want)
+				}
 			}
-			got, err := c.ConstructPayload(tt.permutation)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("ConstructPayload() error = %v, wantErr %v", err, tt.wantErr)
-				return
-			}
-			if err == nil {
-				gotString, _ := io.ReadAll(got)
-				if string(gotString) != tt.want {
-					t.Errorf("ConstructPayload() = %v, want %v", string(gotString), tt.want)
-				}
 			}
 		})
 	}
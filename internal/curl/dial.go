@@ -0,0 +1,44 @@
+package curl
+
+import (
+	"context"
+	"faast-go/internal/config"
+	"net"
+	"time"
+)
+
+// newDialer returns the dial function of the transport. Connections go to
+// the unix socket when one is given, otherwise to the address the host is
+// resolved to, with the resolve overrides taking precedence over DNS. The
+// URL keeps its host, so the Host header and SNI are unchanged.
+func newDialer(settings *config.YamlConfig) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	if settings.DNSServer != "" {
+		server := settings.DNSServer
+		if _, _, err := net.SplitHostPort(server); err != nil {
+			server = net.JoinHostPort(server, "53")
+		}
+		dialer.Resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, network, server)
+			},
+		}
+	}
+
+	hosts := settings.ResolveHosts
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if settings.UnixSocket != "" {
+			return dialer.DialContext(ctx, "unix", settings.UnixSocket)
+		}
+		if host, port, err := net.SplitHostPort(addr); err == nil {
+			if ip, ok := hosts[addr]; ok {
+				addr = net.JoinHostPort(ip, port)
+			} else if ip, ok := hosts[host]; ok {
+				addr = net.JoinHostPort(ip, port)
+			}
+		}
+		return dialer.DialContext(ctx, network, addr)
+	}
+}
@@ -0,0 +1,133 @@
+package curl
+
+import (
+	"context"
+	"encoding/binary"
+	"faast-go/internal/config"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+// dnsStandIn is a DNS server answering every A query with 127.0.0.1, and
+// every other query with no records.
+func dnsStandIn(t *testing.T) string {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			// The question is the name, then its type and class.
+			end := 12
+			for end < n && buf[end] != 0 {
+				end += int(buf[end]) + 1
+			}
+			end += 5
+			if end > n {
+				continue
+			}
+			answer := binary.BigEndian.Uint16(buf[end-4:]) == 1
+			reply := append([]byte{}, buf[:end]...)
+			binary.BigEndian.PutUint16(reply[2:], 0x8180)
+			binary.BigEndian.PutUint16(reply[4:], 1)
+			binary.BigEndian.PutUint16(reply[6:], 0)
+			binary.BigEndian.PutUint16(reply[8:], 0)
+			binary.BigEndian.PutUint16(reply[10:], 0)
+			if answer {
+				binary.BigEndian.PutUint16(reply[6:], 1)
+				reply = append(reply, 0xc0, 12, 0, 1, 0, 1, 0, 0, 0, 60, 0, 4, 127, 0, 0, 1)
+			}
+			conn.WriteTo(reply, addr)
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+func TestSendCurl_Dial(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "tcp "+r.Host)
+	}))
+	defer server.Close()
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+
+	socket := filepath.Join(t.TempDir(), "app.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unixServer := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "unix "+r.Host+r.URL.Path)
+	})}
+	go unixServer.Serve(listener)
+	defer unixServer.Close()
+
+	tests := []struct {
+		name     string
+		url      string
+		settings config.YamlConfig
+		want     string
+		wantErr  bool
+	}{
+		{
+			name:     "Resolve host and port",
+			url:      "http://backend.faast.test:" + port + "/",
+			settings: config.YamlConfig{ResolveHosts: map[string]string{"backend.faast.test:" + port: "127.0.0.1"}},
+			want:     "tcp backend.faast.test:" + port,
+		},
+		{
+			name:     "Resolve host on any port",
+			url:      "http://backend.faast.test:" + port + "/",
+			settings: config.YamlConfig{ResolveHosts: map[string]string{"backend.faast.test": "127.0.0.1"}},
+			want:     "tcp backend.faast.test:" + port,
+		},
+		{
+			name:     "Resolve another port",
+			url:      "http://backend.faast.test:" + port + "/",
+			settings: config.YamlConfig{ResolveHosts: map[string]string{"backend.faast.test:1": "127.0.0.1"}, DNSServer: "127.0.0.1:1"},
+			wantErr:  true,
+		},
+		{
+			name:     "DNS server",
+			url:      "http://backend.faast.test:" + port + "/",
+			settings: config.YamlConfig{DNSServer: dnsStandIn(t)},
+			want:     "tcp backend.faast.test:" + port,
+		},
+		{
+			name:     "Unix socket",
+			url:      "http://localhost/api",
+			settings: config.YamlConfig{UnixSocket: socket},
+			want:     "unix localhost/api",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.settings.Endpoint = tt.url
+			tt.settings.Timeout = 5
+			c, err := NewCurlConfig(&tt.settings)
+			if err != nil {
+				t.Fatalf("NewCurlConfig failed: %v", err)
+			}
+			if c.Client.Transport.(*http.Transport).Proxy != nil {
+				t.Error("the proxy environment variables should not be used")
+			}
+			res, err := c.SendCurl(context.Background(), &Payload{Method: "GET", URL: tt.url, Header: http.Header{}})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SendCurl() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && string(res.Body) != tt.want {
+				t.Errorf("Body = %q, want %q", res.Body, tt.want)
+			}
+		})
+	}
+}
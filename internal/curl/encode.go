@@ -0,0 +1,69 @@
+package curl
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"net/url"
+	"strings"
+	"unicode/utf16"
+)
+
+// namedEncoders are the encoders a field can chain, by name.
+var namedEncoders = map[string]func(string) string{
+	"none":        func(value string) string { return value },
+	"url":         url.QueryEscape,
+	"double-url":  func(value string) string { return url.QueryEscape(url.QueryEscape(value)) },
+	"base64":      func(value string) string { return base64.StdEncoding.EncodeToString([]byte(value)) },
+	"base64url":   func(value string) string { return base64.RawURLEncoding.EncodeToString([]byte(value)) },
+	"hex":         func(value string) string { return hex.EncodeToString([]byte(value)) },
+	"html-entity": html.EscapeString,
+	"json-escape": jsonEscape,
+	"unicode":     unicodeEscape,
+	"md5":         func(value string) string { sum := md5.Sum([]byte(value)); return hex.EncodeToString(sum[:]) },
+	"sha1":        func(value string) string { sum := sha1.Sum([]byte(value)); return hex.EncodeToString(sum[:]) },
+	"sha256":      func(value string) string { sum := sha256.Sum256([]byte(value)); return hex.EncodeToString(sum[:]) },
+}
+
+// unicodeEscape writes every character as a \uXXXX escape, with characters
+// outside the Basic Multilingual Plane as a surrogate pair.
+func unicodeEscape(value string) string {
+	var b strings.Builder
+	for _, unit := range utf16.Encode([]rune(value)) {
+		fmt.Fprintf(&b, "\\u%04x", unit)
+	}
+	return b.String()
+}
+
+// encoderChain returns the encoders with the given names as one function.
+func encoderChain(names []string) (func(string) string, error) {
+	chain := make([]func(string) string, len(names))
+	for i, name := range names {
+		encoder, ok := namedEncoders[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown encoder %q", name)
+		}
+		chain[i] = encoder
+	}
+	return func(value string) string {
+		for _, encoder := range chain {
+			value = encoder(value)
+		}
+		return value
+	}, nil
+}
+
+// escaper applies escape to the fields placed as they are given. The values
+// of fields with encoders are placed exactly as the encoders left them.
+func (c *CurlConfig) escaper(escape func(string) string) func(field int, value string) string {
+	return func(field int, value string) string {
+		if escape == nil || c.encoders[field] != nil {
+			return value
+		}
+		return escape(value)
+	}
+}
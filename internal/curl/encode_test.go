@@ -0,0 +1,43 @@
+package curl
+
+import "testing"
+
+func TestEncoderChain(t *testing.T) {
+	tests := []struct {
+		encoders []string
+		value    string
+		want     string
+		wantErr  bool
+	}{
+		{encoders: []string{"none"}, value: "a b&c", want: "a b&c"},
+		{encoders: []string{"url"}, value: "a b&c", want: "a+b%26c"},
+		{encoders: []string{"double-url"}, value: "a b&c", want: "a%2Bb%2526c"},
+		{encoders: []string{"base64"}, value: "admin:admin", want: "YWRtaW46YWRtaW4="},
+		{encoders: []string{"base64url"}, value: "??>", want: "Pz8-"},
+		{encoders: []string{"hex"}, value: "ab", want: "6162"},
+		{encoders: []string{"html-entity"}, value: `<a href="x">&`, want: "&lt;a href=&#34;x&#34;&gt;&amp;"},
+		{encoders: []string{"json-escape"}, value: "a\"b\\c\n<", want: `a\"b\\c\n<`},
+		{encoders: []string{"unicode"}, value: "a<é😀", want: `\u0061\u003c\u00e9\ud83d\ude00`},
+		{encoders: []string{"md5"}, value: "password", want: "5f4dcc3b5aa765d61d8327deb882cf99"},
+		{encoders: []string{"sha1"}, value: "password", want: "5baa61e4c9b93f3f0682250b6cf8331b7ee68fd8"},
+		{encoders: []string{"sha256"}, value: "password", want: "5e884898da28047151d0e56f8dc6292773603d0d6aabbdd62a11ef721d1542d8"},
+		{encoders: []string{"md5", "base64"}, value: "password", want: "NWY0ZGNjM2I1YWE3NjVkNjFkODMyN2RlYjg4MmNmOTk="},
+		{encoders: []string{"base64", "url"}, value: "??>", want: "Pz8%2B"},
+		{encoders: []string{"rot13"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			encode, err := encoderChain(tt.encoders)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("encoderChain() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := encode(tt.value); got != tt.want {
+				t.Errorf("%v(%q) = %q, want %q", tt.encoders, tt.value, got, tt.want)
+			}
+		})
+	}
+}
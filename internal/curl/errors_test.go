@@ -0,0 +1,30 @@
+package curl
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"syscall"
+	"testing"
+)
+
+func TestErrorKind(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{fmt.Errorf("wrapped: %w", context.DeadlineExceeded), "timeout"},
+		{&net.DNSError{Err: "no such host", Name: "example.invalid"}, "dns"},
+		{&net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}, "connection refused"},
+		{fmt.Errorf("read: %w", syscall.ECONNRESET), "connection reset"},
+		{fmt.Errorf("error from response: %w", io.EOF), "connection reset"},
+		{fmt.Errorf("%w (status 429)", ErrThrottled), "throttled"},
+		{fmt.Errorf("something else"), "other"},
+	}
+	for _, tt := range tests {
+		if got := ErrorKind(tt.err); got != tt.want {
+			t.Errorf("ErrorKind(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package curl
+
+import (
+	"context"
+	"errors"
+	"faast-go/internal/config"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrNoProxies is returned when every proxy of the pool has been evicted.
+var ErrNoProxies = errors.New("no live proxies")
+
+type proxyKey struct{}
+
+// proxyPool rotates requests over a list of proxies. A proxy that cannot be
+// connected to failures times in a row is evicted, and checked again every
+// interval until it accepts connections.
+type proxyPool struct {
+	proxies  []*proxy
+	failures int
+	interval time.Duration
+	next     atomic.Uint64
+}
+
+type proxy struct {
+	url *url.URL
+
+	mu       sync.Mutex
+	failures int
+	dead     bool
+	checkAt  time.Time
+	checking bool
+}
+
+// proxyChoice records the proxy a request went through.
+type proxyChoice struct {
+	proxy *proxy
+}
+
+func newProxyPool(proxies []string, health config.ProxyHealthConfig) (*proxyPool, error) {
+	p := &proxyPool{failures: max(health.Failures, 1)}
+	p.interval, _ = time.ParseDuration(health.Interval)
+	for _, raw := range proxies {
+		u, err := url.Parse(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy %q: %w", raw, err)
+		}
+		p.proxies = append(p.proxies, &proxy{url: u})
+	}
+	return p, nil
+}
+
+// setProxy routes the requests of the transport through the configured
+// proxy or proxy pool. Without either, the proxy environment variables are
+// used, unless the dialer decides where the target is: a proxy would connect
+// to it on its own.
+func (c *CurlConfig) setProxy(transport *http.Transport, settings *config.YamlConfig) error {
+	if settings.UnixSocket != "" || len(settings.ResolveHosts) > 0 || settings.DNSServer != "" {
+		transport.Proxy = nil
+	}
+	if settings.Proxy != "" {
+		u, err := url.Parse(settings.Proxy)
+		if err != nil {
+			return fmt.Errorf("invalid proxy %q: %w", settings.Proxy, err)
+		}
+		transport.Proxy = http.ProxyURL(u)
+	}
+	if len(settings.Proxies) > 0 {
+		pool, err := newProxyPool(settings.Proxies, settings.ProxyHealth)
+		if err != nil {
+			return err
+		}
+		c.proxies = pool
+		transport.Proxy = pool.proxy
+	}
+	return nil
+}
+
+// proxy is the Proxy function of the transport. It takes the next live
+// proxy, and records it in the proxyChoice of the request context.
+func (p *proxyPool) proxy(req *http.Request) (*url.URL, error) {
+	// Every proxy skipped takes a turn, so the live ones share the requests
+	// evenly.
+	for range p.proxies {
+		candidate := p.proxies[(p.next.Add(1)-1)%uint64(len(p.proxies))]
+		if !candidate.alive(p.interval) {
+			continue
+		}
+		if choice, ok := req.Context().Value(proxyKey{}).(*proxyChoice); ok {
+			choice.proxy = candidate
+		}
+		return candidate.url, nil
+	}
+	return nil, ErrNoProxies
+}
+
+// report counts the result of a request that went through the proxy
+// recorded in ctx. Only failing to connect to the proxy itself counts
+// against it.
+func (p *proxyPool) report(ctx context.Context, err error) {
+	choice, ok := ctx.Value(proxyKey{}).(*proxyChoice)
+	if !ok || choice.proxy == nil {
+		return
+	}
+	var opErr *net.OpError
+	failed := errors.As(err, &opErr) && opErr.Op == "proxyconnect"
+
+	pr := choice.proxy
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+	if !failed {
+		pr.failures = 0
+		return
+	}
+	pr.failures++
+	if pr.failures >= p.failures && !pr.dead {
+		pr.dead = true
+		pr.checkAt = time.Now().Add(p.interval)
+	}
+}
+
+// alive reports whether the proxy can be used. When an evicted proxy is due
+// to be checked again, the check runs in the background.
+func (pr *proxy) alive(interval time.Duration) bool {
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+	if !pr.dead {
+		return true
+	}
+	if !pr.checking && interval > 0 && time.Now().After(pr.checkAt) {
+		pr.checking = true
+		go pr.check(interval)
+	}
+	return false
+}
+
+// check connects to the proxy, and brings it back when that succeeds.
+func (pr *proxy) check(interval time.Duration) {
+	conn, err := net.DialTimeout("tcp", pr.url.Host, 5*time.Second)
+	if err == nil {
+		conn.Close()
+	}
+
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+	pr.checking = false
+	if err != nil {
+		pr.checkAt = time.Now().Add(interval)
+		return
+	}
+	pr.dead = false
+	pr.failures = 0
+}
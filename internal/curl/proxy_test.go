@@ -0,0 +1,190 @@
+package curl
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"faast-go/internal/config"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// proxyStandIn answers every request itself, with the name of the proxy and
+// the URL it was asked for.
+func proxyStandIn(t *testing.T, name string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Proxy-Authorization", r.Header.Get("Proxy-Authorization"))
+		io.WriteString(w, name+" "+r.URL.String())
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// socksStandIn is a SOCKS5 proxy accepting only the given user and password.
+func socksStandIn(t *testing.T, user, password string) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveSocks(conn, user, password)
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func serveSocks(conn net.Conn, user, password string) {
+	defer conn.Close()
+	buf := make([]byte, 512)
+	// Greeting, then username/password authentication (RFC 1929).
+	if _, err := io.ReadFull(conn, buf[:2]); err != nil {
+		return
+	}
+	io.ReadFull(conn, buf[:buf[1]])
+	conn.Write([]byte{5, 2})
+	io.ReadFull(conn, buf[:2])
+	gotUser := make([]byte, buf[1])
+	io.ReadFull(conn, gotUser)
+	io.ReadFull(conn, buf[:1])
+	gotPassword := make([]byte, buf[0])
+	io.ReadFull(conn, gotPassword)
+	if string(gotUser) != user || string(gotPassword) != password {
+		conn.Write([]byte{1, 1})
+		return
+	}
+	conn.Write([]byte{1, 0})
+
+	// CONNECT to an IPv4 address.
+	if _, err := io.ReadFull(conn, buf[:10]); err != nil || buf[3] != 1 {
+		return
+	}
+	target := net.JoinHostPort(net.IP(buf[4:8]).String(), strconv.Itoa(int(buf[8])<<8|int(buf[9])))
+	upstream, err := net.Dial("tcp", target)
+	if err != nil {
+		conn.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0})
+		return
+	}
+	defer upstream.Close()
+	conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+	go io.Copy(upstream, conn)
+	io.Copy(conn, upstream)
+}
+
+func TestSendCurl_Proxy(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "direct")
+	}))
+	defer target.Close()
+	httpProxy := proxyStandIn(t, "http-proxy")
+	socks := socksStandIn(t, "user", "secret")
+	auth := "Basic " + base64.StdEncoding.EncodeToString([]byte("user:secret"))
+
+	tests := []struct {
+		name       string
+		proxy      string
+		want       string
+		wantHeader string
+		wantErr    bool
+	}{
+		{name: "HTTP proxy", proxy: httpProxy.URL, want: "http-proxy " + target.URL + "/"},
+		{name: "HTTP proxy with auth", proxy: "http://user:secret@" + httpProxy.Listener.Addr().String(), want: "http-proxy " + target.URL + "/", wantHeader: auth},
+		{name: "SOCKS5 proxy with auth", proxy: "socks5://user:secret@" + socks, want: "direct"},
+		{name: "SOCKS5 proxy with wrong password", proxy: "socks5://user:wrong@" + socks, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := NewCurlConfig(&config.YamlConfig{Endpoint: target.URL, Proxy: tt.proxy, Timeout: 5})
+			if err != nil {
+				t.Fatalf("NewCurlConfig failed: %v", err)
+			}
+			res, err := c.SendCurl(context.Background(), &Payload{Method: "GET", URL: target.URL + "/", Header: http.Header{}})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SendCurl() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if string(res.Body) != tt.want {
+				t.Errorf("Body = %q, want %q", res.Body, tt.want)
+			}
+			if got := res.Header.Get("X-Proxy-Authorization"); got != tt.wantHeader {
+				t.Errorf("Proxy-Authorization = %q, want %q", got, tt.wantHeader)
+			}
+		})
+	}
+}
+
+func TestSendCurl_ProxyPool(t *testing.T) {
+	first := proxyStandIn(t, "first")
+	second := proxyStandIn(t, "second")
+	dead := proxyStandIn(t, "dead")
+	dead.Close()
+
+	c, err := NewCurlConfig(&config.YamlConfig{
+		Endpoint:    "http://example.com",
+		Proxies:     []string{dead.URL, first.URL, second.URL},
+		ProxyHealth: config.ProxyHealthConfig{Failures: 2, Interval: "1h"},
+		Timeout:     5,
+	})
+	if err != nil {
+		t.Fatalf("NewCurlConfig failed: %v", err)
+	}
+
+	var got []string
+	for i := 0; i < 8; i++ {
+		res, err := c.SendCurl(context.Background(), &Payload{Method: "GET", URL: "http://example.com/", Header: http.Header{}})
+		if err != nil {
+			got = append(got, "error")
+			continue
+		}
+		got = append(got, string(res.Body[:len(res.Body)-len(" http://example.com/")]))
+	}
+	// The dead proxy is evicted after its second failure.
+	want := []string{"error", "first", "second", "error", "first", "second", "first", "second"}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("responses = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestProxyPool_Check(t *testing.T) {
+	server := proxyStandIn(t, "proxy")
+	pool, err := newProxyPool([]string{server.URL}, config.ProxyHealthConfig{Failures: 1, Interval: "1ms"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	choice := &proxyChoice{proxy: pool.proxies[0]}
+	ctx := context.WithValue(context.Background(), proxyKey{}, choice)
+	pool.report(ctx, &net.OpError{Op: "proxyconnect", Err: errors.New("refused")})
+
+	req, _ := http.NewRequest("GET", "http://example.com", nil)
+	if _, err := pool.proxy(req); !errors.Is(err, ErrNoProxies) {
+		t.Fatalf("proxy() error = %v, want ErrNoProxies", err)
+	}
+
+	// The evicted proxy is checked in the background, and is back once it
+	// accepts a connection.
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		time.Sleep(5 * time.Millisecond)
+		if _, err := pool.proxy(req); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("proxy was not brought back after a successful check")
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package curl
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// replaySlots is the number of replays sent at the same time.
+const replaySlots = 4
+
+// Replayer sends the requests of hits again through a replay proxy, so they
+// show up with their responses in the history of an intercepting proxy.
+// Replays are sent in the background, and their responses are discarded.
+type Replayer struct {
+	config *CurlConfig
+	client *http.Client
+	slots  chan struct{}
+	wg     sync.WaitGroup
+}
+
+// NewReplayer returns a Replayer sending through proxy, or nil when proxy
+// is empty. Certificates are not verified, as the intercepting proxy signs
+// them with its own CA.
+func NewReplayer(c *CurlConfig, proxy string) (*Replayer, error) {
+	if proxy == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(proxy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid replay proxy %q: %w", proxy, err)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if base, ok := c.Client.Transport.(*http.Transport); ok {
+		transport = base.Clone()
+	}
+	transport.Proxy = http.ProxyURL(u)
+	// The replay proxy connects to the target itself, and is reached over
+	// plain TCP.
+	transport.DialContext = (&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}).DialContext
+	if transport.TLSClientConfig == nil {
+		transport.TLSClientConfig = &tls.Config{}
+	}
+	transport.TLSClientConfig.InsecureSkipVerify = true
+
+	return &Replayer{
+		config: c,
+		client: &http.Client{
+			Timeout:       c.Client.Timeout,
+			Transport:     transport,
+			CheckRedirect: c.Client.CheckRedirect,
+		},
+		slots: make(chan struct{}, replaySlots),
+	}, nil
+}
+
+// Replay sends the payload again in the background. A nil Replayer does
+// nothing.
+func (r *Replayer) Replay(payload *Payload) {
+	if r == nil || payload == nil {
+		return
+	}
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		r.slots <- struct{}{}
+		defer func() { <-r.slots }()
+		if err := r.send(payload); err != nil {
+			fmt.Printf("Warning: error replaying %s %s: %v\n", payload.Method, payload.URL, err)
+		}
+	}()
+}
+
+func (r *Replayer) send(payload *Payload) error {
+	req, err := r.config.newRequest(context.Background(), payload)
+	if err != nil {
+		return err
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, err = io.Copy(io.Discard, resp.Body)
+	return err
+}
+
+// Wait waits for the replays sent so far.
+func (r *Replayer) Wait() {
+	if r != nil {
+		r.wg.Wait()
+	}
+}
@@ -0,0 +1,74 @@
+package curl
+
+import (
+	"context"
+	"faast-go/internal/config"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestReplayer(t *testing.T) {
+	var mu sync.Mutex
+	var replayed []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		replayed = append(replayed, r.Method+" "+r.URL.String()+" "+r.Header.Get("User-Agent")+" "+r.Header.Get("X-Token")+" "+string(body))
+		mu.Unlock()
+	}))
+	defer proxy.Close()
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer target.Close()
+
+	settings := &config.YamlConfig{
+		Endpoint:   target.URL + "/login",
+		Headers:    []string{"X-Token: {{token}}"},
+		Body:       "user={{user}}",
+		UserAgents: []string{"agent-a", "agent-b"},
+		Fields: []config.Field{
+			{Name: "user", Wordlist: "users.txt"},
+			{Name: "token", Value: "t1"},
+		},
+	}
+	settings.SetDefaults()
+	c, err := NewCurlConfig(settings)
+	if err != nil {
+		t.Fatalf("NewCurlConfig failed: %v", err)
+	}
+	replayer, err := NewReplayer(c, proxy.URL)
+	if err != nil {
+		t.Fatalf("NewReplayer failed: %v", err)
+	}
+
+	for _, user := range []string{"admin", "root"} {
+		payload, err := c.ConstructPayload([]string{user})
+		if err != nil {
+			t.Fatalf("ConstructPayload failed: %v", err)
+		}
+		if _, err := c.SendCurl(context.Background(), payload); err != nil {
+			t.Fatalf("SendCurl failed: %v", err)
+		}
+		if user == "root" {
+			replayer.Replay(payload)
+		}
+	}
+	replayer.Wait()
+
+	// Only the replayed request goes through the proxy, as it was sent.
+	want := "POST " + target.URL + "/login agent-b t1 user=root"
+	if len(replayed) != 1 || replayed[0] != want {
+		t.Errorf("replayed = %q, want [%q]", replayed, want)
+	}
+}
+
+func TestNewReplayer_Disabled(t *testing.T) {
+	replayer, err := NewReplayer(&CurlConfig{Client: &http.Client{}}, "")
+	if err != nil || replayer != nil {
+		t.Fatalf("NewReplayer() = %v, %v, want nil without a proxy", replayer, err)
+	}
+	replayer.Replay(&Payload{Method: "GET", URL: "http://example.com"})
+	replayer.Wait()
+}
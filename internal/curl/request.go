@@ -0,0 +1,109 @@
+package curl
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// rawRequest is a request read from a raw HTTP request file, such as one
+// saved from an intercepting proxy. Markers are kept as they are.
+type rawRequest struct {
+	method  string
+	url     string
+	headers []string
+	body    string
+}
+
+// droppedHeaders are recalculated for every request instead of being copied
+// from the request file.
+var droppedHeaders = map[string]bool{
+	"Host":              true,
+	"Content-Length":    true,
+	"Transfer-Encoding": true,
+	"Accept-Encoding":   true,
+	"Connection":        true,
+}
+
+func loadRequestFile(filename string, scheme string) (*rawRequest, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("error opening request file %s: %w", filename, err)
+	}
+	defer file.Close()
+
+	request, err := parseRawRequest(file, scheme)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing request file %s: %w", filename, err)
+	}
+	return request, nil
+}
+
+func parseRawRequest(r io.Reader, scheme string) (*rawRequest, error) {
+	reader := bufio.NewReader(r)
+
+	requestLine, err := readLine(reader)
+	if err != nil {
+		return nil, fmt.Errorf("error reading request line: %w", err)
+	}
+	parts := strings.Fields(requestLine)
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("invalid request line %q", requestLine)
+	}
+
+	request := &rawRequest{method: parts[0]}
+	host := ""
+	for {
+		line, err := readLine(reader)
+		if err != nil && err != io.EOF {
+			return nil, fmt.Errorf("error reading headers: %w", err)
+		}
+		if line == "" {
+			break
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid header %q", line)
+		}
+		name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+		value = strings.TrimSpace(value)
+		if name == "Host" {
+			host = value
+		}
+		if !droppedHeaders[name] {
+			request.headers = append(request.headers, name+": "+value)
+		}
+	}
+
+	target := parts[1]
+	if strings.HasPrefix(target, "http://") || strings.HasPrefix(target, "https://") {
+		request.url = target
+	} else {
+		if host == "" {
+			return nil, fmt.Errorf("request has no Host header")
+		}
+		if scheme == "" {
+			scheme = "https"
+		}
+		request.url = scheme + "://" + host + target
+	}
+
+	body, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("error reading body: %w", err)
+	}
+	request.body = string(body)
+
+	return request, nil
+}
+
+func readLine(reader *bufio.Reader) (string, error) {
+	line, err := reader.ReadString('\n')
+	if err == io.EOF && line != "" {
+		err = nil
+	}
+	return strings.TrimRight(line, "\r\n"), err
+}
@@ -0,0 +1,236 @@
+package curl
+
+import (
+	"context"
+	"faast-go/internal/config"
+	"io"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseRawRequest(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		scheme  string
+		want    *rawRequest
+		wantErr bool
+	}{
+		{
+			name: "Request with body",
+			raw: "POST /login?next=%2F HTTP/1.1\r\n" +
+				"Host: example.com:8443\r\n" +
+				"Content-Type: application/json\r\n" +
+				"Content-Length: 31\r\n" +
+				"Accept-Encoding: gzip, deflate\r\n" +
+				"cookie: session={{session}}\r\n" +
+				"\r\n" +
+				`{"user":"{{user}}","pass":"x"}` + "\n",
+			scheme: "https",
+			want: &rawRequest{
+				method: "POST",
+				url:    "https://example.com:8443/login?next=%2F",
+				headers: []string{
+					"Content-Type: application/json",
+					"Cookie: session={{session}}",
+				},
+				body: `{"user":"{{user}}","pass":"x"}` + "\n",
+			},
+		},
+		{
+			name:   "Request without body and default scheme",
+			raw:    "GET /users/{{id}} HTTP/1.1\nHost: example.com\nX-Api-Key: abc",
+			scheme: "",
+			want: &rawRequest{
+				method:  "GET",
+				url:     "https://example.com/users/{{id}}",
+				headers: []string{"X-Api-Key: abc"},
+				body:    "",
+			},
+		},
+		{
+			name:   "Absolute target",
+			raw:    "GET http://internal.example.com/a HTTP/1.1\nHost: example.com\n\n",
+			scheme: "https",
+			want: &rawRequest{
+				method: "GET",
+				url:    "http://internal.example.com/a",
+				body:   "",
+			},
+		},
+		{
+			name:    "Missing host",
+			raw:     "GET / HTTP/1.1\n\n",
+			wantErr: true,
+		},
+		{
+			name:    "Invalid request line",
+			raw:     "GET\n",
+			wantErr: true,
+		},
+		{
+			name:    "Invalid header",
+			raw:     "GET / HTTP/1.1\nHost example.com\n\n",
+			wantErr: true,
+		},
+		{
+			name:    "Empty file",
+			raw:     "",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseRawRequest(strings.NewReader(tt.raw), tt.scheme)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseRawRequest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseRawRequest() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequestFile(t *testing.T) {
+	var gotBody, gotContentLength, gotCookie, gotPath string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		gotBody = string(body)
+		gotContentLength = r.Header.Get("Content-Length")
+		gotCookie = r.Header.Get("Cookie")
+		gotPath = r.URL.Path
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	host := strings.TrimPrefix(server.URL, "http://")
+	raw := "PUT /users/{{id}} HTTP/1.1\r\n" +
+		"Host: " + host + "\r\n" +
+		"Content-Type: application/json\r\n" +
+		"Content-Length: 2\r\n" +
+		"Cookie: session=abc\r\n" +
+		"\r\n" +
+		`{"name":"{{name}}"}`
+	requestFile := filepath.Join(t.TempDir(), "request.txt")
+	if err := os.WriteFile(requestFile, []byte(raw), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	settings := &config.YamlConfig{
+		Request: requestFile,
+		Scheme:  "http",
+		Fields: []config.Field{
+			{Name: "id", Wordlist: "ids.txt"},
+			{Name: "name", Wordlist: "names.txt"},
+		},
+	}
+	settings.SetDefaults()
+	c, err := NewCurlConfig(settings)
+	if err != nil {
+		t.Fatalf("NewCurlConfig failed: %v", err)
+	}
+
+	payload, err := c.ConstructPayload([]string{"42", "a much longer name"})
+	if err != nil {
+		t.Fatalf("ConstructPayload failed: %v", err)
+	}
+	if payload.Method != "PUT" {
+		t.Errorf("Method = %s, want PUT", payload.Method)
+	}
+
+	if _, err := c.SendCurl(context.Background(), payload); err != nil {
+		t.Fatalf("SendCurl failed: %v", err)
+	}
+
+	wantBody := `{"name":"a much longer name"}`
+	if gotBody != wantBody {
+		t.Errorf("body = %q, want %q", gotBody, wantBody)
+	}
+	if gotContentLength != "29" {
+		t.Errorf("Content-Length = %q, want 29", gotContentLength)
+	}
+	if gotCookie != "session=abc" {
+		t.Errorf("Cookie = %q, want session=abc", gotCookie)
+	}
+	if gotPath != "/users/42" {
+		t.Errorf("path = %q, want /users/42", gotPath)
+	}
+
+	if _, err := NewCurlConfig(&config.YamlConfig{Request: filepath.Join(t.TempDir(), "missing.txt")}); err == nil {
+		t.Error("NewCurlConfig should fail for a missing request file")
+	}
+}
+
+func TestRequestFile_BodyType(t *testing.T) {
+	var gotUser string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		gotUser = r.FormValue("user")
+	}))
+	defer server.Close()
+
+	writeRequest := func(contentType, body string) string {
+		raw := "POST /login HTTP/1.1\r\n" +
+			"Host: " + strings.TrimPrefix(server.URL, "http://") + "\r\n" +
+			"Content-Type: " + contentType + "\r\n" +
+			"\r\n" + body
+		filename := filepath.Join(t.TempDir(), "request.txt")
+		if err := os.WriteFile(filename, []byte(raw), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return filename
+	}
+	newConfig := func(request, bodyType string) (*CurlConfig, error) {
+		settings := &config.YamlConfig{
+			Request:  request,
+			Scheme:   "http",
+			BodyType: bodyType,
+			Fields:   []config.Field{{Name: "user", Wordlist: "users.txt"}},
+		}
+		settings.SetDefaults()
+		return NewCurlConfig(settings)
+	}
+
+	// the boundary of the file is replaced by the one of the encoded body
+	c, err := newConfig(writeRequest("multipart/form-data; boundary=OLD", ""), "multipart")
+	if err != nil {
+		t.Fatalf("NewCurlConfig failed: %v", err)
+	}
+	payload, err := c.ConstructPayload([]string{"admin"})
+	if err != nil {
+		t.Fatalf("ConstructPayload failed: %v", err)
+	}
+	_, params, _ := mime.ParseMediaType(payload.Header.Get("Content-Type"))
+	if !strings.Contains(payload.Body, "--"+params["boundary"]+"\r\n") || params["boundary"] == "OLD" {
+		t.Errorf("Content-Type %q does not match the body %q", payload.Header.Get("Content-Type"), payload.Body)
+	}
+	res, err := c.SendCurl(context.Background(), payload)
+	if err != nil || res.StatusCode != http.StatusOK || gotUser != "admin" {
+		t.Errorf("server could not parse the multipart body: %v, %v, user %q", res, err, gotUser)
+	}
+
+	c, err = newConfig(writeRequest("application/x-www-form-urlencoded", ""), "json")
+	if err != nil {
+		t.Fatalf("NewCurlConfig failed: %v", err)
+	}
+	if payload, _ = c.ConstructPayload([]string{"admin"}); payload.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", payload.Header.Get("Content-Type"))
+	}
+
+	for _, bodyType := range []string{"multipart", "query"} {
+		if _, err := newConfig(writeRequest("text/plain", "user={{user}}"), bodyType); err == nil {
+			t.Errorf("NewCurlConfig should fail for a request file body with bodyType %s", bodyType)
+		}
+	}
+}
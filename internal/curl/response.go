@@ -0,0 +1,54 @@
+package curl
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Response is a response with its body already read, so it can be matched
+// and recorded after the connection has been released. Location is the
+// Location header, or the final URL when redirects were followed.
+type Response struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	Duration   time.Duration
+	Location   string
+}
+
+// RetryAfter parses the Retry-After header, either in seconds or as a date.
+func (r *Response) RetryAfter() (time.Duration, bool) {
+	value := strings.TrimSpace(r.Header.Get("Retry-After"))
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0), true
+	}
+	return 0, false
+}
+
+func (r *Response) Size() int {
+	return len(r.Body)
+}
+
+func (r *Response) Words() int {
+	return len(bytes.Fields(r.Body))
+}
+
+func (r *Response) Lines() int {
+	if len(r.Body) == 0 {
+		return 0
+	}
+	lines := bytes.Count(r.Body, []byte("\n"))
+	if r.Body[len(r.Body)-1] != '\n' {
+		lines++
+	}
+	return lines
+}
@@ -0,0 +1,58 @@
+package curl
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestResponseCounts(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      string
+		wantSize  int
+		wantWords int
+		wantLines int
+	}{
+		{name: "Empty body", body: "", wantSize: 0, wantWords: 0, wantLines: 0},
+		{name: "Single line", body: "hello world", wantSize: 11, wantWords: 2, wantLines: 1},
+		{name: "Trailing newline", body: "a b\nc\n", wantSize: 6, wantWords: 3, wantLines: 2},
+		{name: "Blank lines", body: "a\n\n\tb  c", wantSize: 8, wantWords: 3, wantLines: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := &Response{Body: []byte(tt.body)}
+			if got := res.Size(); got != tt.wantSize {
+				t.Errorf("Size() = %d, want %d", got, tt.wantSize)
+			}
+			if got := res.Words(); got != tt.wantWords {
+				t.Errorf("Words() = %d, want %d", got, tt.wantWords)
+			}
+			if got := res.Lines(); got != tt.wantLines {
+				t.Errorf("Lines() = %d, want %d", got, tt.wantLines)
+			}
+		})
+	}
+}
+
+func TestResponse_RetryAfter(t *testing.T) {
+	tests := []struct {
+		value  string
+		want   time.Duration
+		wantOK bool
+	}{
+		{"", 0, false},
+		{"3", 3 * time.Second, true},
+		{" 0 ", 0, true},
+		{"soon", 0, false},
+		{"Mon, 02 Jan 2006 15:04:05 GMT", 0, true},
+	}
+	for _, tt := range tests {
+		res := &Response{Header: http.Header{"Retry-After": {tt.value}}}
+		got, ok := res.RetryAfter()
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("RetryAfter() with %q = %v, %v, want %v, %v", tt.value, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package curl
+
+import (
+	"fmt"
+	"strings"
+)
+
+// template is a string containing {{name}} markers, with every marker
+// resolved to the index of the field it refers to.
+type template struct {
+	literals []string
+	fields   []int
+}
+
+func parseTemplate(s string, fieldIndex map[string]int) (*template, error) {
+	t := &template{}
+	for {
+		start := strings.Index(s, "{{")
+		if start < 0 {
+			break
+		}
+		end := strings.Index(s[start:], "}}")
+		if end < 0 {
+			return nil, fmt.Errorf("unterminated marker in %q", s)
+		}
+		name := strings.TrimSpace(s[start+2 : start+end])
+		index, ok := fieldIndex[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown field %q in marker", name)
+		}
+		t.literals = append(t.literals, s[:start])
+		t.fields = append(t.fields, index)
+		s = s[start+end+2:]
+	}
+	t.literals = append(t.literals, s)
+	return t, nil
+}
+
+// render substitutes the field values into the template, each passed
+// through escape.
+func (t *template) render(values []string, escape func(field int, value string) string) string {
+	var b strings.Builder
+	for i, field := range t.fields {
+		b.WriteString(t.literals[i])
+		b.WriteString(escape(field, values[field]))
+	}
+	b.WriteString(t.literals[len(t.literals)-1])
+	return b.String()
+}
+
+func (t *template) uses(field int) bool {
+	for _, f := range t.fields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
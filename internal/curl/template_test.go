@@ -0,0 +1,73 @@
+package curl
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestParseTemplate(t *testing.T) {
+	fieldIndex := map[string]int{"user": 0, "pass": 1}
+	tests := []struct {
+		name     string
+		template string
+		escape   func(string) string
+		want     string
+		wantErr  bool
+	}{
+		{
+			name:     "No markers",
+			template: "plain text",
+			want:     "plain text",
+		},
+		{
+			name:     "Markers",
+			template: "{{user}}:{{ pass }}/{{user}}",
+			want:     "admin:p@ss word/admin",
+		},
+		{
+			name:     "Escaped values",
+			template: "u={{user}}&p={{pass}}",
+			escape:   url.QueryEscape,
+			want:     "u=admin&p=p%40ss+word",
+		},
+		{
+			name:     "Unknown field",
+			template: "{{nope}}",
+			wantErr:  true,
+		},
+		{
+			name:     "Unterminated marker",
+			template: "{{user",
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := parseTemplate(tt.template, fieldIndex)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseTemplate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			c := &CurlConfig{encoders: make([]func(string) string, 2)}
+			if got := tmpl.render([]string{"admin", "p@ss word"}, c.escaper(tt.escape)); got != tt.want {
+				t.Errorf("render() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTemplateUses(t *testing.T) {
+	tmpl, err := parseTemplate("/{{b}}", map[string]int{"a": 0, "b": 1})
+	if err != nil {
+		t.Fatalf("parseTemplate failed: %v", err)
+	}
+	if tmpl.uses(0) {
+		t.Error("uses(0) = true, want false")
+	}
+	if !tmpl.uses(1) {
+		t.Error("uses(1) = false, want true")
+	}
+}
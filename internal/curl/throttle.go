@@ -0,0 +1,204 @@
+package curl
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"faast-go/internal/config"
+
+	"golang.org/x/time/rate"
+)
+
+// ErrThrottled is returned by SendCurl, together with the response, when the
+// target rate limited the request.
+var ErrThrottled = errors.New("throttled")
+
+// Throttle lowers the rate of the rate limiter while the target is throttling
+// us, and raises it back step by step once it stops.
+type Throttle struct {
+	// Retries is how often a throttled request is sent again.
+	Retries int
+
+	mu         sync.Mutex
+	limiter    *rate.Limiter
+	max        rate.Limit
+	min        rate.Limit
+	status     map[int]bool
+	signatures [][]byte
+	pause      time.Duration
+
+	pausedUntil time.Time
+	lastChange  time.Time
+	// ceiling is the rate at which ramping up stops and the configured rate
+	// is restored. Without a configured rate it is the rate seen before the
+	// first throttling.
+	ceiling rate.Limit
+	// sent counts the requests of the current second, previous those of the
+	// second before. They give the rate to start from when the limiter had
+	// no limit.
+	second   time.Time
+	sent     int
+	previous int
+}
+
+func newThrottle(limiter *rate.Limiter, settings config.ThrottleConfig) *Throttle {
+	t := &Throttle{
+		Retries: settings.Retries,
+		limiter: limiter,
+		max:     limiter.Limit(),
+		ceiling: limiter.Limit(),
+		min:     rate.Limit(settings.MinRate),
+		status:  make(map[int]bool, len(settings.Status)),
+	}
+	t.pause, _ = time.ParseDuration(settings.Pause)
+	for _, status := range settings.Status {
+		t.status[status] = true
+	}
+	for _, signature := range settings.Signatures {
+		t.signatures = append(t.signatures, bytes.ToLower([]byte(signature)))
+	}
+	return t
+}
+
+// newLimiter builds a limiter allowing requestsPerSecond, with a burst of
+// one second worth of requests. math.MaxFloat64 means no limit.
+func newLimiter(requestsPerSecond float64) *rate.Limiter {
+	limit := rate.Limit(requestsPerSecond)
+	if requestsPerSecond == math.MaxFloat64 {
+		limit = rate.Inf
+	}
+	return rate.NewLimiter(limit, burst(limit))
+}
+
+func burst(limit rate.Limit) int {
+	if limit == rate.Inf {
+		return 1
+	}
+	return int(math.Max(1, math.Min(math.Ceil(float64(limit)), math.MaxInt32)))
+}
+
+func (t *Throttle) enabled() bool {
+	return len(t.status) > 0 || len(t.signatures) > 0
+}
+
+// Wait blocks while requests are paused, then waits for the rate limiter.
+// Both stop when ctx is cancelled, even during a long Retry-After pause.
+func (t *Throttle) Wait(ctx context.Context) error {
+	t.mu.Lock()
+	now := time.Now()
+	pause := t.pausedUntil.Sub(now)
+	t.count(now)
+	t.mu.Unlock()
+
+	if pause > 0 {
+		timer := time.NewTimer(pause)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+
+	t.mu.Lock()
+	t.rampUp(time.Now())
+	t.mu.Unlock()
+	return t.limiter.Wait(ctx)
+}
+
+func (t *Throttle) count(now time.Time) {
+	switch elapsed := now.Sub(t.second); {
+	case elapsed < time.Second:
+	case elapsed < 2*time.Second:
+		t.second, t.previous, t.sent = t.second.Add(time.Second), t.sent, 0
+	default:
+		t.second, t.previous, t.sent = now, 0, 0
+	}
+	t.sent++
+}
+
+// rampUp raises the rate by half after every pause without throttling, until
+// it is back at the configured rate. The first of these starts when the
+// throttling pause ends, not when it began.
+func (t *Throttle) rampUp(now time.Time) {
+	current := t.limiter.Limit()
+	if current == t.max || now.Sub(later(t.lastChange, t.pausedUntil)) < t.pause {
+		return
+	}
+	next := current * 1.5
+	if next >= t.ceiling {
+		next = t.max
+	}
+	t.setLimit(next, now)
+}
+
+func later(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+func (t *Throttle) peak() int {
+	return max(t.sent, t.previous)
+}
+
+func (t *Throttle) setLimit(limit rate.Limit, now time.Time) {
+	t.limiter.SetLimitAt(now, limit)
+	t.limiter.SetBurstAt(now, burst(limit))
+	t.lastChange = now
+}
+
+// Throttled reports whether res shows the target is rate limiting us.
+func (t *Throttle) Throttled(res *Response) bool {
+	if t.status[res.StatusCode] {
+		return true
+	}
+	if len(t.signatures) == 0 {
+		return false
+	}
+	body := bytes.ToLower(res.Body)
+	for _, signature := range t.signatures {
+		if bytes.Contains(body, signature) {
+			return true
+		}
+	}
+	return false
+}
+
+// backOff halves the rate and pauses all requests. Throttled responses that
+// arrive while already paused only extend the pause, so a burst of them
+// lowers the rate once.
+func (t *Throttle) backOff(res *Response) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	pause := t.pause
+	if wait, ok := res.RetryAfter(); ok {
+		pause = wait
+	}
+	paused := now.Before(t.pausedUntil)
+	if until := now.Add(pause); until.After(t.pausedUntil) {
+		t.pausedUntil = until
+	}
+	if paused {
+		return
+	}
+
+	current := t.limiter.Limit()
+	if current == rate.Inf {
+		current = rate.Limit(max(t.peak(), 1))
+		t.ceiling = current
+	}
+	next := max(current/2, min(t.min, current))
+	t.setLimit(next, now)
+	fmt.Printf("\nThrottled (status %d), pausing for %s and lowering the rate to %s requests/s\n",
+		res.StatusCode, pause, strconv.FormatFloat(float64(next), 'f', -1, 64))
+}
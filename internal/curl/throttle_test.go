@@ -0,0 +1,174 @@
+package curl
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"faast-go/internal/config"
+
+	"golang.org/x/time/rate"
+)
+
+func TestNewLimiter(t *testing.T) {
+	if limiter := newLimiter(math.MaxFloat64); limiter.Limit() != rate.Inf {
+		t.Errorf("newLimiter(MaxFloat64) limit = %v, want Inf", limiter.Limit())
+	}
+	if limiter := newLimiter(10.5); limiter.Limit() != 10.5 || limiter.Burst() != 11 {
+		t.Errorf("newLimiter(10.5) = %v/%d, want 10.5/11", limiter.Limit(), limiter.Burst())
+	}
+	if limiter := newLimiter(0.2); limiter.Burst() != 1 {
+		t.Errorf("newLimiter(0.2) burst = %d, want 1", limiter.Burst())
+	}
+	if limiter := newLimiter(1e300); limiter.Burst() != math.MaxInt32 {
+		t.Errorf("newLimiter(1e300) burst = %d, want MaxInt32", limiter.Burst())
+	}
+}
+
+func TestThrottle_Throttled(t *testing.T) {
+	throttle := newThrottle(newLimiter(10), config.ThrottleConfig{
+		Status:     []int{429},
+		Signatures: []string{"Too Many Requests"},
+	})
+	tests := []struct {
+		name string
+		res  *Response
+		want bool
+	}{
+		{"status", &Response{StatusCode: 429}, true},
+		{"signature", &Response{StatusCode: 200, Body: []byte("<h1>too many requests</h1>")}, true},
+		{"normal", &Response{StatusCode: 503, Body: []byte("down")}, false},
+	}
+	for _, tt := range tests {
+		if got := throttle.Throttled(tt.res); got != tt.want {
+			t.Errorf("%s: Throttled() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestThrottle_BackOffAndRampUp(t *testing.T) {
+	limiter := newLimiter(40)
+	throttle := newThrottle(limiter, config.ThrottleConfig{Status: []int{429}, Pause: "1s", MinRate: 8})
+	res := &Response{StatusCode: 429, Header: http.Header{}}
+
+	throttle.backOff(res)
+	if limiter.Limit() != 20 {
+		t.Errorf("after backOff limit = %v, want 20", limiter.Limit())
+	}
+	throttle.backOff(res)
+	if limiter.Limit() != 20 {
+		t.Errorf("backOff while paused changed the limit to %v", limiter.Limit())
+	}
+
+	// Pretend the pause is over.
+	throttle.pausedUntil = time.Time{}
+	throttle.backOff(res)
+	throttle.pausedUntil = time.Time{}
+	throttle.backOff(res)
+	if limiter.Limit() != 8 {
+		t.Errorf("limit = %v, want minRate 8", limiter.Limit())
+	}
+
+	now := time.Now()
+	throttle.rampUp(now)
+	if limiter.Limit() != 8 {
+		t.Errorf("rampUp before a full pause changed the limit to %v", limiter.Limit())
+	}
+	now = throttle.pausedUntil
+	throttle.rampUp(now)
+	if limiter.Limit() != 8 {
+		t.Errorf("rampUp right after the pause changed the limit to %v", limiter.Limit())
+	}
+	for _, want := range []rate.Limit{12, 18, 27, 40} {
+		now = now.Add(time.Second)
+		throttle.rampUp(now)
+		if limiter.Limit() != want {
+			t.Errorf("rampUp limit = %v, want %v", limiter.Limit(), want)
+		}
+	}
+}
+
+func TestThrottle_RetryAfter(t *testing.T) {
+	throttle := newThrottle(newLimiter(math.MaxFloat64), config.ThrottleConfig{Status: []int{503}, Pause: "10s", MinRate: 1})
+	throttle.backOff(&Response{StatusCode: 503, Header: http.Header{"Retry-After": {"0"}}})
+	if time.Until(throttle.pausedUntil) > time.Second {
+		t.Errorf("pause ignored Retry-After, paused until %v", throttle.pausedUntil)
+	}
+	if throttle.limiter.Limit() == rate.Inf {
+		t.Error("backOff without a configured rate kept the limiter unlimited")
+	}
+
+	start := time.Now()
+	throttle.pausedUntil = start.Add(100 * time.Millisecond)
+	if err := throttle.Wait(context.Background()); err != nil {
+		t.Fatalf("Wait() error = %v", err)
+	}
+	if time.Since(start) < 100*time.Millisecond {
+		t.Error("Wait() returned before the pause ended")
+	}
+}
+
+func TestSendCurl_Throttled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "0")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	c, err := NewCurlConfig(&config.YamlConfig{
+		Endpoint:  server.URL,
+		RateLimit: math.MaxFloat64,
+		Throttle:  config.ThrottleConfig{Status: []int{429}, Pause: "1s", MinRate: 1, Retries: 2},
+	})
+	if err != nil {
+		t.Fatalf("NewCurlConfig() error = %v", err)
+	}
+	res, err := c.SendCurl(context.Background(), &Payload{Method: "GET", URL: server.URL, Header: http.Header{}})
+	if !errors.Is(err, ErrThrottled) {
+		t.Errorf("SendCurl() error = %v, want ErrThrottled", err)
+	}
+	if res == nil || res.StatusCode != 429 {
+		t.Errorf("SendCurl() should return the throttled response, got %v", res)
+	}
+}
+
+func TestSendCurl_CancelDuringPause(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Retry-After", "3600")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	c, err := NewCurlConfig(&config.YamlConfig{
+		Endpoint:  server.URL,
+		RateLimit: math.MaxFloat64,
+		Throttle:  config.ThrottleConfig{Status: []int{429}, Pause: "1s", MinRate: 1, Retries: 2},
+	})
+	if err != nil {
+		t.Fatalf("NewCurlConfig() error = %v", err)
+	}
+	payload := &Payload{Method: "GET", URL: server.URL, Header: http.Header{}}
+	if _, err := c.SendCurl(context.Background(), payload); !errors.Is(err, ErrThrottled) {
+		t.Fatalf("SendCurl() error = %v, want ErrThrottled", err)
+	}
+
+	// the next request waits out the hour long pause until it is cancelled
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	start := time.Now()
+	if _, err := c.SendCurl(ctx, payload); !errors.Is(err, ErrNotSent) || !errors.Is(err, context.Canceled) {
+		t.Errorf("SendCurl() error = %v, want ErrNotSent after cancel", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("SendCurl() returned %v after cancel, want right away", elapsed)
+	}
+	if requests != 1 {
+		t.Errorf("expected 1 request, got %d", requests)
+	}
+}
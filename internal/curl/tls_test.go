@@ -0,0 +1,137 @@
+package curl
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"faast-go/internal/config"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// writeClientCert writes a self-signed client certificate and its key as
+// PEM files.
+func writeClientCert(t *testing.T, dir string) (certFile, keyFile string, cert *x509.Certificate) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "faast client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile = filepath.Join(dir, "client.pem")
+	keyFile = filepath.Join(dir, "client.key")
+	os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644)
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)
+	cert, _ = x509.ParseCertificate(der)
+	return certFile, keyFile, cert
+}
+
+func TestSendCurl_TLS(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, clientCert := writeClientCert(t, dir)
+
+	var mu sync.Mutex
+	serverName := ""
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.TLS = &tls.Config{
+		MaxVersion: tls.VersionTLS12,
+		GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+			mu.Lock()
+			serverName = hello.ServerName
+			mu.Unlock()
+			return nil, nil
+		},
+	}
+	server.StartTLS()
+	defer server.Close()
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCert)
+	mtls := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	mtls.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	mtls.StartTLS()
+	defer mtls.Close()
+
+	ca := filepath.Join(dir, "ca.pem")
+	os.WriteFile(ca, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0644)
+
+	tests := []struct {
+		name           string
+		tls            config.TLSConfig
+		mtls           bool
+		wantServerName string
+		wantErr        bool
+	}{
+		{name: "Untrusted certificate", wantErr: true},
+		{name: "Skip verify", tls: config.TLSConfig{InsecureSkipVerify: true}},
+		{name: "Custom CA", tls: config.TLSConfig{CA: ca}},
+		{name: "Server name", tls: config.TLSConfig{CA: ca, ServerName: "example.com"}, wantServerName: "example.com"},
+		{name: "Server name not in the certificate", tls: config.TLSConfig{CA: ca, ServerName: "other.test"}, wantErr: true},
+		{name: "Minimum version above the server", tls: config.TLSConfig{InsecureSkipVerify: true, MinVersion: "1.3"}, wantErr: true},
+		{name: "Client certificate", tls: config.TLSConfig{InsecureSkipVerify: true, Cert: certFile, Key: keyFile}, mtls: true},
+		{name: "Missing client certificate", tls: config.TLSConfig{InsecureSkipVerify: true}, mtls: true, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := server.URL
+			if tt.mtls {
+				target = mtls.URL
+			}
+			c, err := NewCurlConfig(&config.YamlConfig{Endpoint: target, TLS: tt.tls, Timeout: 5})
+			if err != nil {
+				t.Fatalf("NewCurlConfig failed: %v", err)
+			}
+			_, err = c.SendCurl(context.Background(), &Payload{Method: "GET", URL: target, Header: http.Header{}})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SendCurl() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if tt.wantServerName != "" && serverName != tt.wantServerName {
+				t.Errorf("SNI = %q, want %q", serverName, tt.wantServerName)
+			}
+		})
+	}
+}
+
+func TestNewTLSConfig_Errors(t *testing.T) {
+	dir := t.TempDir()
+	notPEM := filepath.Join(dir, "ca.txt")
+	os.WriteFile(notPEM, []byte("not a certificate"), 0644)
+
+	for _, settings := range []config.TLSConfig{
+		{CA: filepath.Join(dir, "missing.pem")},
+		{CA: notPEM},
+		{Cert: notPEM, Key: notPEM},
+	} {
+		if _, err := newTLSConfig(settings); err == nil {
+			t.Errorf("newTLSConfig(%+v) should fail", settings)
+		}
+	}
+}
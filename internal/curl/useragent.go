@@ -0,0 +1,57 @@
+package curl
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+type workerKey struct{}
+
+// WithWorker marks the requests sent with ctx as sent by the given worker,
+// which picks the user agent when they rotate per worker.
+func WithWorker(ctx context.Context, worker int) context.Context {
+	return context.WithValue(ctx, workerKey{}, worker)
+}
+
+// userAgents rotates the User-Agent header over a list of agents, with every
+// request, per worker or at random.
+type userAgents struct {
+	agents   []string
+	rotation string
+	next     atomic.Uint64
+}
+
+// loadUserAgents returns the given agents followed by the ones in filename,
+// one per line.
+func loadUserAgents(agents []string, filename string) ([]string, error) {
+	if filename == "" {
+		return agents, nil
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("error reading user agent file: %w", err)
+	}
+	agents = append([]string(nil), agents...)
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			agents = append(agents, line)
+		}
+	}
+	return agents, nil
+}
+
+func (u *userAgents) pick(ctx context.Context) string {
+	switch u.rotation {
+	case "worker":
+		worker, _ := ctx.Value(workerKey{}).(int)
+		return u.agents[worker%len(u.agents)]
+	case "random":
+		return u.agents[rand.IntN(len(u.agents))]
+	default:
+		return u.agents[(u.next.Add(1)-1)%uint64(len(u.agents))]
+	}
+}
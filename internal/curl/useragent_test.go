@@ -0,0 +1,61 @@
+package curl
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestUserAgents_Pick(t *testing.T) {
+	agents := []string{"a", "b", "c"}
+	tests := []struct {
+		rotation string
+		workers  []int
+		want     []string
+	}{
+		{rotation: "request", workers: []int{0, 0, 0, 0}, want: []string{"a", "b", "c", "a"}},
+		{rotation: "request", workers: []int{0, 1, 2, 3}, want: []string{"a", "b", "c", "a"}},
+		{rotation: "worker", workers: []int{0, 0, 1, 4}, want: []string{"a", "a", "b", "b"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rotation, func(t *testing.T) {
+			u := &userAgents{agents: agents, rotation: tt.rotation}
+			var got []string
+			for _, worker := range tt.workers {
+				got = append(got, u.pick(WithWorker(context.Background(), worker)))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("pick() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	u := &userAgents{agents: agents, rotation: "random"}
+	for i := 0; i < 20; i++ {
+		if got := u.pick(context.Background()); got != "a" && got != "b" && got != "c" {
+			t.Fatalf("random pick() = %q, not one of %v", got, agents)
+		}
+	}
+}
+
+func TestLoadUserAgents(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "agents.txt")
+	if err := os.WriteFile(filename, []byte("Mozilla/5.0 (X11)\r\n\ncurl/8.0\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := loadUserAgents([]string{"custom"}, filename)
+	if err != nil {
+		t.Fatalf("loadUserAgents failed: %v", err)
+	}
+	if want := []string{"custom", "Mozilla/5.0 (X11)", "curl/8.0"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("loadUserAgents() = %q, want %q", got, want)
+	}
+
+	if _, err := loadUserAgents(nil, filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Error("loadUserAgents should fail when the file does not exist")
+	}
+}
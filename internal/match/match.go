@@ -0,0 +1,203 @@
+package match
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+
+	"faast-go/internal/config"
+	"faast-go/internal/curl"
+)
+
+type Matcher interface {
+	Match(res *curl.Response) bool
+}
+
+// Engine decides which responses are hits: a response is a hit when it is
+// selected by the match rules and not by the filter rules.
+type Engine struct {
+	match  *Set
+	filter *Set
+}
+
+func New(config *config.YamlConfig) (*Engine, error) {
+	match, err := NewSet(config.Match)
+	if err != nil {
+		return nil, fmt.Errorf("invalid match rules: %w", err)
+	}
+	filter, err := NewSet(config.Filter)
+	if err != nil {
+		return nil, fmt.Errorf("invalid filter rules: %w", err)
+	}
+
+	// validateType is the old way of filtering out the "normal" response
+	switch config.ValidateType {
+	case "size":
+		filter.Add(&rangeMatcher{value: size, ranges: []Range{{Min: int64(config.SizeDefault), Max: int64(config.SizeDefault)}}})
+	case "code":
+		filter.Add(&rangeMatcher{value: status, ranges: []Range{{Min: int64(config.CodeDefault), Max: int64(config.CodeDefault)}}})
+	case "":
+	default:
+		fmt.Printf("Warning: invalid validate type '%s'. Ignoring it.\n", config.ValidateType)
+	}
+
+	return &Engine{match: match, filter: filter}, nil
+}
+
+func (e *Engine) IsHit(res *curl.Response) bool {
+	if len(e.match.matchers) > 0 && !e.match.Match(res) {
+		return false
+	}
+	return len(e.filter.matchers) == 0 || !e.filter.Match(res)
+}
+
+// AddFilter filters out every response the matcher selects.
+func (e *Engine) AddFilter(m Matcher) {
+	e.filter.Add(m)
+}
+
+// Set combines matchers with "and" or "or".
+type Set struct {
+	all      bool
+	matchers []Matcher
+}
+
+func NewSet(rules config.Rules) (*Set, error) {
+	s := &Set{all: rules.Mode == "and"}
+
+	numeric := []struct {
+		values []string
+		value  func(*curl.Response) int64
+		parse  func(string) (int64, error)
+	}{
+		{rules.Status, status, parseInt},
+		{rules.Size, size, parseInt},
+		{rules.Words, words, parseInt},
+		{rules.Lines, lines, parseInt},
+		{rules.Time, duration, parseDuration},
+	}
+	for _, n := range numeric {
+		if len(n.values) == 0 {
+			continue
+		}
+		ranges, err := ParseRanges(n.values, n.parse)
+		if err != nil {
+			return nil, err
+		}
+		s.Add(&rangeMatcher{value: n.value, ranges: ranges})
+	}
+
+	if len(rules.Regex) > 0 {
+		patterns, err := compile(rules.Regex)
+		if err != nil {
+			return nil, err
+		}
+		s.Add(&bodyRegexMatcher{patterns: patterns})
+	}
+	if len(rules.HeaderRegex) > 0 {
+		patterns, err := compile(rules.HeaderRegex)
+		if err != nil {
+			return nil, err
+		}
+		s.Add(&headerRegexMatcher{patterns: patterns})
+	}
+	if len(rules.Headers) > 0 {
+		s.Add(&headerMatcher{names: rules.Headers})
+	}
+
+	return s, nil
+}
+
+func (s *Set) Add(m Matcher) {
+	s.matchers = append(s.matchers, m)
+}
+
+func (s *Set) Match(res *curl.Response) bool {
+	for _, m := range s.matchers {
+		if m.Match(res) != s.all {
+			return !s.all
+		}
+	}
+	return s.all
+}
+
+func compile(patterns []string) ([]*regexp.Regexp, error) {
+	compiled := make([]*regexp.Regexp, len(patterns))
+	for i, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex %q: %w", pattern, err)
+		}
+		compiled[i] = re
+	}
+	return compiled, nil
+}
+
+func status(res *curl.Response) int64   { return int64(res.StatusCode) }
+func size(res *curl.Response) int64     { return int64(res.Size()) }
+func words(res *curl.Response) int64    { return int64(res.Words()) }
+func lines(res *curl.Response) int64    { return int64(res.Lines()) }
+func duration(res *curl.Response) int64 { return int64(res.Duration) }
+
+// rangeMatcher selects responses where a measured value falls in any of the
+// ranges.
+type rangeMatcher struct {
+	value  func(*curl.Response) int64
+	ranges []Range
+}
+
+func (m *rangeMatcher) Match(res *curl.Response) bool {
+	value := m.value(res)
+	for _, r := range m.ranges {
+		if r.Contains(value) {
+			return true
+		}
+	}
+	return false
+}
+
+type bodyRegexMatcher struct {
+	patterns []*regexp.Regexp
+}
+
+func (m *bodyRegexMatcher) Match(res *curl.Response) bool {
+	for _, re := range m.patterns {
+		if re.Match(res.Body) {
+			return true
+		}
+	}
+	return false
+}
+
+// headerRegexMatcher matches the patterns against every "Name: value" header
+// line of the response.
+type headerRegexMatcher struct {
+	patterns []*regexp.Regexp
+}
+
+func (m *headerRegexMatcher) Match(res *curl.Response) bool {
+	for name, values := range res.Header {
+		for _, value := range values {
+			line := name + ": " + value
+			for _, re := range m.patterns {
+				if re.MatchString(line) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+type headerMatcher struct {
+	names []string
+}
+
+func (m *headerMatcher) Match(res *curl.Response) bool {
+	for _, name := range m.names {
+		if _, ok := res.Header[http.CanonicalHeaderKey(name)]; ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,157 @@
+package match
+
+import (
+	"faast-go/internal/config"
+	"faast-go/internal/curl"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestEngine_IsHit(t *testing.T) {
+	response := &curl.Response{
+		StatusCode: 200,
+		Header:     http.Header{"Set-Cookie": {"role=admin"}, "X-Debug": {"1"}},
+		Body:       []byte("Welcome back\nadmin user\n"),
+		Duration:   750 * time.Millisecond,
+	}
+
+	tests := []struct {
+		name   string
+		config config.YamlConfig
+		want   bool
+	}{
+		{
+			name: "No rules",
+			want: true,
+		},
+		{
+			name:   "Status in range",
+			config: config.YamlConfig{Match: config.Rules{Status: []string{"200-299"}}},
+			want:   true,
+		},
+		{
+			name:   "Status not in set",
+			config: config.YamlConfig{Match: config.Rules{Status: []string{"301", "302"}}},
+			want:   false,
+		},
+		{
+			name:   "Body size uses the read body",
+			config: config.YamlConfig{Match: config.Rules{Size: []string{"24"}}},
+			want:   true,
+		},
+		{
+			name:   "Words and lines",
+			config: config.YamlConfig{Match: config.Rules{Mode: "and", Words: []string{"4"}, Lines: []string{"2"}}},
+			want:   true,
+		},
+		{
+			name:   "Body regex",
+			config: config.YamlConfig{Match: config.Rules{Regex: []string{"(?i)welcome"}}},
+			want:   true,
+		},
+		{
+			name:   "Header regex",
+			config: config.YamlConfig{Match: config.Rules{HeaderRegex: []string{"^Set-Cookie: role=admin$"}}},
+			want:   true,
+		},
+		{
+			name:   "Header presence",
+			config: config.YamlConfig{Match: config.Rules{Headers: []string{"x-debug"}}},
+			want:   true,
+		},
+		{
+			name:   "Missing header",
+			config: config.YamlConfig{Match: config.Rules{Headers: []string{"X-Powered-By"}}},
+			want:   false,
+		},
+		{
+			name:   "Response time",
+			config: config.YamlConfig{Match: config.Rules{Time: []string{"500ms-"}}},
+			want:   true,
+		},
+		{
+			name:   "Or mode needs one rule",
+			config: config.YamlConfig{Match: config.Rules{Status: []string{"404"}, Regex: []string{"admin"}}},
+			want:   true,
+		},
+		{
+			name:   "And mode needs every rule",
+			config: config.YamlConfig{Match: config.Rules{Mode: "and", Status: []string{"404"}, Regex: []string{"admin"}}},
+			want:   false,
+		},
+		{
+			name: "Filtered out",
+			config: config.YamlConfig{
+				Match:  config.Rules{Status: []string{"200"}},
+				Filter: config.Rules{Regex: []string{"Welcome"}},
+			},
+			want: false,
+		},
+		{
+			name:   "Filter in and mode",
+			config: config.YamlConfig{Filter: config.Rules{Mode: "and", Status: []string{"200"}, Size: []string{"0"}}},
+			want:   true,
+		},
+		{
+			name:   "Legacy validate size",
+			config: config.YamlConfig{ValidateType: "size", SizeDefault: 24},
+			want:   false,
+		},
+		{
+			name:   "Legacy validate size mismatch",
+			config: config.YamlConfig{ValidateType: "size", SizeDefault: 100},
+			want:   true,
+		},
+		{
+			name:   "Legacy validate code",
+			config: config.YamlConfig{ValidateType: "code", CodeDefault: 200},
+			want:   false,
+		},
+		{
+			name:   "Legacy validate code mismatch",
+			config: config.YamlConfig{ValidateType: "code", CodeDefault: 404},
+			want:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine, err := New(&tt.config)
+			if err != nil {
+				t.Fatalf("New() failed: %v", err)
+			}
+			if got := engine.IsHit(response); got != tt.want {
+				t.Errorf("IsHit() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNew_InvalidRules(t *testing.T) {
+	configs := []config.YamlConfig{
+		{Match: config.Rules{Status: []string{"ok"}}},
+		{Filter: config.Rules{Regex: []string{"("}}},
+		{Match: config.Rules{Time: []string{"fast"}}},
+	}
+	for _, c := range configs {
+		if _, err := New(&c); err == nil {
+			t.Errorf("New(%+v) should have returned an error", c)
+		}
+	}
+}
+
+func TestEngine_AddFilter(t *testing.T) {
+	engine, err := New(&config.YamlConfig{})
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	response := &curl.Response{StatusCode: 302}
+	if !engine.IsHit(response) {
+		t.Fatal("expected a hit before adding a filter")
+	}
+	engine.AddFilter(&rangeMatcher{value: status, ranges: []Range{{Min: 302, Max: 302}}})
+	if engine.IsHit(response) {
+		t.Error("expected the added filter to remove the hit")
+	}
+}
@@ -0,0 +1,69 @@
+package match
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Range is an inclusive range of values. A value like "200" is a range of a
+// single value, and either side of "min-max" can be left open.
+type Range struct {
+	Min int64
+	Max int64
+}
+
+func (r Range) Contains(value int64) bool {
+	return value >= r.Min && value <= r.Max
+}
+
+func ParseRanges(values []string, parse func(string) (int64, error)) ([]Range, error) {
+	ranges := make([]Range, 0, len(values))
+	for _, value := range values {
+		r, err := parseRange(strings.TrimSpace(value), parse)
+		if err != nil {
+			return nil, err
+		}
+		ranges = append(ranges, r)
+	}
+	return ranges, nil
+}
+
+func parseRange(value string, parse func(string) (int64, error)) (Range, error) {
+	minStr, maxStr, isRange := strings.Cut(value, "-")
+	if !isRange {
+		n, err := parse(value)
+		if err != nil {
+			return Range{}, fmt.Errorf("invalid value %q: %w", value, err)
+		}
+		return Range{Min: n, Max: n}, nil
+	}
+
+	r := Range{Min: 0, Max: math.MaxInt64}
+	var err error
+	if minStr != "" {
+		if r.Min, err = parse(minStr); err != nil {
+			return Range{}, fmt.Errorf("invalid range %q: %w", value, err)
+		}
+	}
+	if maxStr != "" {
+		if r.Max, err = parse(maxStr); err != nil {
+			return Range{}, fmt.Errorf("invalid range %q: %w", value, err)
+		}
+	}
+	if minStr == "" && maxStr == "" || r.Min > r.Max {
+		return Range{}, fmt.Errorf("invalid range %q", value)
+	}
+	return r, nil
+}
+
+func parseInt(value string) (int64, error) {
+	return strconv.ParseInt(value, 10, 64)
+}
+
+func parseDuration(value string) (int64, error) {
+	d, err := time.ParseDuration(value)
+	return int64(d), err
+}
@@ -0,0 +1,76 @@
+package match
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseRanges(t *testing.T) {
+	tests := []struct {
+		name    string
+		values  []string
+		parse   func(string) (int64, error)
+		want    []Range
+		wantErr bool
+	}{
+		{
+			name:   "Single values and ranges",
+			values: []string{"200", "300-399", " 500 "},
+			parse:  parseInt,
+			want:   []Range{{200, 200}, {300, 399}, {500, 500}},
+		},
+		{
+			name:   "Open ranges",
+			values: []string{"1000-", "-10"},
+			parse:  parseInt,
+			want:   []Range{{1000, math.MaxInt64}, {0, 10}},
+		},
+		{
+			name:   "Durations",
+			values: []string{"500ms-", "1s-2s"},
+			parse:  parseDuration,
+			want:   []Range{{int64(500 * time.Millisecond), math.MaxInt64}, {int64(time.Second), int64(2 * time.Second)}},
+		},
+		{
+			name:    "Not a number",
+			values:  []string{"abc"},
+			parse:   parseInt,
+			wantErr: true,
+		},
+		{
+			name:    "Reversed range",
+			values:  []string{"399-300"},
+			parse:   parseInt,
+			wantErr: true,
+		},
+		{
+			name:    "Empty range",
+			values:  []string{"-"},
+			parse:   parseInt,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseRanges(tt.values, tt.parse)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseRanges() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseRanges() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRangeContains(t *testing.T) {
+	r := Range{Min: 300, Max: 399}
+	for value, want := range map[int64]bool{299: false, 300: true, 350: true, 399: true, 400: false} {
+		if got := r.Contains(value); got != want {
+			t.Errorf("Contains(%d) = %v, want %v", value, got, want)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package output
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"faast-go/internal/curl"
+)
+
+const (
+	Text  = "text"
+	JSONL = "jsonl"
+	CSV   = "csv"
+)
+
+// Record is a single hit as it is written to the output.
+type Record struct {
+	Index      int               `json:"index"`
+	Payload    map[string]string `json:"payload"`
+	Status     int               `json:"status"`
+	Size       int               `json:"size"`
+	Words      int               `json:"words"`
+	Lines      int               `json:"lines"`
+	DurationMS float64           `json:"duration_ms"`
+	Location   string            `json:"location,omitempty"`
+	Timestamp  time.Time         `json:"timestamp"`
+
+	values []string
+}
+
+// NewRecord builds the record of a hit. fields names the values of the
+// permutation, in order.
+func NewRecord(index int, fields []string, values []string, res *curl.Response) Record {
+	payload := make(map[string]string, len(fields))
+	for i, field := range fields {
+		payload[field] = values[i]
+	}
+	return Record{
+		Index:      index,
+		Payload:    payload,
+		Status:     res.StatusCode,
+		Size:       res.Size(),
+		Words:      res.Words(),
+		Lines:      res.Lines(),
+		DurationMS: float64(res.Duration.Microseconds()) / 1000,
+		Location:   res.Location,
+		Timestamp:  time.Now(),
+		values:     values,
+	}
+}
+
+// FormatFor returns the format to write filename in. Without an explicit
+// format it is taken from the file extension, falling back to text.
+func FormatFor(format string, filename string) string {
+	if format != "" {
+		return format
+	}
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".jsonl", ".json":
+		return JSONL
+	case ".csv":
+		return CSV
+	}
+	return Text
+}
+
+// Writer writes records in one of the output formats.
+type Writer struct {
+	w      io.Writer
+	closer io.Closer
+	format string
+	fields []string
+	csv    *csv.Writer
+}
+
+func New(w io.Writer, format string, fields []string) (*Writer, error) {
+	writer := &Writer{w: w, format: format, fields: fields}
+	switch format {
+	case Text, JSONL:
+	case CSV:
+		writer.csv = csv.NewWriter(w)
+	default:
+		return nil, fmt.Errorf("invalid output format %q", format)
+	}
+	return writer, nil
+}
+
+// Create opens filename for writing records. With appendFile set the records
+// are added to the end of an existing file, and a CSV header is only written
+// to an empty file.
+func Create(filename string, format string, fields []string, appendFile bool) (*Writer, error) {
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if appendFile {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	}
+	file, err := os.OpenFile(filename, flags, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("error opening output file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("error opening output file: %w", err)
+	}
+
+	writer, err := New(file, format, fields)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	writer.closer = file
+	if writer.csv != nil && info.Size() == 0 {
+		if err := writer.writeHeader(); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+	return writer, nil
+}
+
+func (w *Writer) writeHeader() error {
+	header := append([]string{"index"}, w.fields...)
+	header = append(header, "status", "size", "words", "lines", "duration_ms", "location", "timestamp")
+	if err := w.csv.Write(header); err != nil {
+		return fmt.Errorf("error writing output: %w", err)
+	}
+	w.csv.Flush()
+	return w.csv.Error()
+}
+
+func (w *Writer) Write(r Record) error {
+	var err error
+	switch w.format {
+	case JSONL:
+		var line []byte
+		line, err = json.Marshal(r)
+		if err == nil {
+			_, err = fmt.Fprintf(w.w, "%s\n", line)
+		}
+	case CSV:
+		row := append([]string{strconv.Itoa(r.Index)}, r.values...)
+		row = append(row,
+			strconv.Itoa(r.Status),
+			strconv.Itoa(r.Size),
+			strconv.Itoa(r.Words),
+			strconv.Itoa(r.Lines),
+			strconv.FormatFloat(r.DurationMS, 'f', -1, 64),
+			r.Location,
+			r.Timestamp.Format(time.RFC3339Nano),
+		)
+		if err = w.csv.Write(row); err == nil {
+			w.csv.Flush()
+			err = w.csv.Error()
+		}
+	default:
+		_, err = fmt.Fprintln(w.w, textRecord(r))
+	}
+	if err != nil {
+		return fmt.Errorf("error writing output: %w", err)
+	}
+	return nil
+}
+
+func textRecord(r Record) string {
+	line := fmt.Sprintf("Payload %v caused an anomaly (status=%d size=%d words=%d lines=%d time=%sms",
+		r.values, r.Status, r.Size, r.Words, r.Lines, strconv.FormatFloat(r.DurationMS, 'f', -1, 64))
+	if r.Location != "" {
+		line += " location=" + r.Location
+	}
+	return line + ")"
+}
+
+// WriteSummary adds the summary to the output. JSON Lines output gets a final
+// line with a single "summary" key, CSV output has no room for it.
+func (w *Writer) WriteSummary(s *Summary) error {
+	var err error
+	switch w.format {
+	case JSONL:
+		var line []byte
+		line, err = json.Marshal(map[string]*Summary{"summary": s})
+		if err == nil {
+			_, err = fmt.Fprintf(w.w, "%s\n", line)
+		}
+	case CSV:
+	default:
+		_, err = fmt.Fprint(w.w, s.String())
+	}
+	if err != nil {
+		return fmt.Errorf("error writing output: %w", err)
+	}
+	return nil
+}
+
+func (w *Writer) Close() error {
+	if w.closer == nil {
+		return nil
+	}
+	return w.closer.Close()
+}
@@ -0,0 +1,157 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"faast-go/internal/curl"
+)
+
+func testRecord() Record {
+	res := &curl.Response{
+		StatusCode: 302,
+		Header:     http.Header{},
+		Body:       []byte("moved here\n"),
+		Duration:   1500 * time.Microsecond,
+		Location:   "/login",
+	}
+	record := NewRecord(3, []string{"user", "pass"}, []string{"admin", "a,b"}, res)
+	record.Timestamp = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	return record
+}
+
+func TestFormatFor(t *testing.T) {
+	tests := []struct {
+		format   string
+		filename string
+		want     string
+	}{
+		{"", "hits.jsonl", JSONL},
+		{"", "hits.JSON", JSONL},
+		{"", "hits.csv", CSV},
+		{"", "hits.txt", Text},
+		{"", "hits", Text},
+		{CSV, "hits.jsonl", CSV},
+	}
+	for _, tt := range tests {
+		if got := FormatFor(tt.format, tt.filename); got != tt.want {
+			t.Errorf("FormatFor(%q, %q) = %q, want %q", tt.format, tt.filename, got, tt.want)
+		}
+	}
+}
+
+func TestWriter_Write(t *testing.T) {
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{
+			format: JSONL,
+			want:   `{"index":3,"payload":{"pass":"a,b","user":"admin"},"status":302,"size":11,"words":2,"lines":1,"duration_ms":1.5,"location":"/login","timestamp":"2024-01-02T03:04:05Z"}` + "\n",
+		},
+		{
+			format: CSV,
+			want:   "3,admin,\"a,b\",302,11,2,1,1.5,/login,2024-01-02T03:04:05Z\n",
+		},
+		{
+			format: Text,
+			want:   "Payload [admin a,b] caused an anomaly (status=302 size=11 words=2 lines=1 time=1.5ms location=/login)\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var b bytes.Buffer
+			w, err := New(&b, tt.format, []string{"user", "pass"})
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+			if err := w.Write(testRecord()); err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+			if b.String() != tt.want {
+				t.Errorf("Write() wrote %q, want %q", b.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestNew_InvalidFormat(t *testing.T) {
+	if _, err := New(&bytes.Buffer{}, "xml", nil); err == nil {
+		t.Error("New() expected an error for an invalid format")
+	}
+}
+
+func TestCreate(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "hits.csv")
+	header := "index,user,pass,status,size,words,lines,duration_ms,location,timestamp\n"
+	row := "3,admin,\"a,b\",302,11,2,1,1.5,/login,2024-01-02T03:04:05Z\n"
+
+	for i, appendFile := range []bool{false, true, false} {
+		w, err := Create(filename, CSV, []string{"user", "pass"}, appendFile)
+		if err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		if err := w.Write(testRecord()); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+		w.Close()
+
+		content, err := os.ReadFile(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := header + row
+		if i == 1 {
+			want += row
+		}
+		if string(content) != want {
+			t.Errorf("run %d wrote %q, want %q", i, content, want)
+		}
+	}
+}
+
+func TestWriter_WriteSummary(t *testing.T) {
+	summary := NewSummary()
+	summary.Add(&curl.Response{StatusCode: 200, Body: []byte("ok")}, nil, true)
+	summary.Finish(2 * time.Second)
+
+	var b bytes.Buffer
+	w, _ := New(&b, JSONL, nil)
+	if err := w.WriteSummary(summary); err != nil {
+		t.Fatalf("WriteSummary() error = %v", err)
+	}
+	var line struct {
+		Summary struct {
+			Requests int            `json:"requests"`
+			Hits     int            `json:"hits"`
+			Seconds  float64        `json:"seconds"`
+			Statuses map[string]int `json:"statuses"`
+		} `json:"summary"`
+	}
+	if err := json.Unmarshal(b.Bytes(), &line); err != nil {
+		t.Fatalf("summary line %q is not json: %v", b.String(), err)
+	}
+	if line.Summary.Requests != 1 || line.Summary.Hits != 1 || line.Summary.Seconds != 2 || line.Summary.Statuses["200"] != 1 {
+		t.Errorf("summary line = %s", b.String())
+	}
+
+	b.Reset()
+	w, _ = New(&b, CSV, nil)
+	w.WriteSummary(summary)
+	if b.Len() != 0 {
+		t.Errorf("CSV summary wrote %q, want nothing", b.String())
+	}
+
+	b.Reset()
+	w, _ = New(&b, Text, nil)
+	w.WriteSummary(summary)
+	if !strings.HasPrefix(b.String(), "Sent 1 requests in 2s") {
+		t.Errorf("text summary = %q", b.String())
+	}
+}
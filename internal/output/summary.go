@@ -0,0 +1,109 @@
+package output
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"faast-go/internal/curl"
+)
+
+// Summary counts the results of a run.
+type Summary struct {
+	Requests int            `json:"requests"`
+	Hits     int            `json:"hits"`
+	Failed   int            `json:"failed"`
+	Duration time.Duration  `json:"-"`
+	Seconds  float64        `json:"seconds"`
+	Failures map[string]int `json:"failures"`
+	Statuses map[int]int    `json:"statuses"`
+	Sizes    map[int]int    `json:"sizes"`
+
+	mu sync.Mutex
+}
+
+// topSizes is how many of the most common response sizes the text summary
+// lists.
+const topSizes = 10
+
+func NewSummary() *Summary {
+	return &Summary{
+		Failures: make(map[string]int),
+		Statuses: make(map[int]int),
+		Sizes:    make(map[int]int),
+	}
+}
+
+// Add counts a result. A result with an error counts as failed, under the
+// kind of failure it was.
+func (s *Summary) Add(res *curl.Response, err error, hit bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Requests++
+	if err != nil {
+		s.Failed++
+		s.Failures[curl.ErrorKind(err)]++
+		return
+	}
+	if hit {
+		s.Hits++
+	}
+	s.Statuses[res.StatusCode]++
+	s.Sizes[res.Size()]++
+}
+
+// Finish records how long the run took.
+func (s *Summary) Finish(duration time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Duration = duration
+	s.Seconds = duration.Seconds()
+}
+
+// String renders the summary for the console. The distributions are sorted
+// by count, most common first.
+func (s *Summary) String() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Sent %d requests in %s: %d hits, %d failed\n", s.Requests, s.Duration.Round(time.Millisecond), s.Hits, s.Failed)
+	if len(s.Statuses) > 0 {
+		fmt.Fprintf(&b, "Status codes: %s\n", distribution(s.Statuses, 0))
+	}
+	if len(s.Sizes) > 0 {
+		fmt.Fprintf(&b, "Sizes: %s\n", distribution(s.Sizes, topSizes))
+	}
+	if len(s.Failures) > 0 {
+		fmt.Fprintf(&b, "Failures: %s\n", distribution(s.Failures, 0))
+	}
+	return b.String()
+}
+
+// distribution formats counts as "key: count" pairs, most common first. A
+// limit above zero keeps only that many of them.
+func distribution[K int | string](counts map[K]int, limit int) string {
+	keys := make([]K, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+
+	parts := make([]string, 0, len(keys))
+	for i, key := range keys {
+		if limit > 0 && i == limit {
+			parts = append(parts, "...")
+			break
+		}
+		parts = append(parts, fmt.Sprintf("%v: %d", key, counts[key]))
+	}
+	return strings.Join(parts, ", ")
+}
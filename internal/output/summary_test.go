@@ -0,0 +1,44 @@
+package output
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"faast-go/internal/curl"
+)
+
+func TestSummary_String(t *testing.T) {
+	summary := NewSummary()
+	for i := 0; i < 3; i++ {
+		summary.Add(&curl.Response{StatusCode: 404, Body: []byte("nope")}, nil, false)
+	}
+	summary.Add(&curl.Response{StatusCode: 200, Body: []byte("welcome")}, nil, true)
+	summary.Add(nil, fmt.Errorf("error from response: %w", context.DeadlineExceeded), false)
+	summary.Finish(1500 * time.Millisecond)
+
+	want := "Sent 5 requests in 1.5s: 1 hits, 1 failed\n" +
+		"Status codes: 404: 3, 200: 1\n" +
+		"Sizes: 4: 3, 7: 1\n" +
+		"Failures: timeout: 1\n"
+	if got := summary.String(); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
+
+func TestSummary_String_NoFailures(t *testing.T) {
+	summary := NewSummary()
+	summary.Add(&curl.Response{StatusCode: 200}, nil, false)
+	want := "Sent 1 requests in 0s: 0 hits, 0 failed\nStatus codes: 200: 1\nSizes: 0: 1\n"
+	if got := summary.String(); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
+
+func TestDistribution_Limit(t *testing.T) {
+	counts := map[int]int{10: 1, 20: 5, 30: 5, 40: 2}
+	if got := distribution(counts, 2); got != "20: 5, 30: 5, ..." {
+		t.Errorf("distribution() = %q", got)
+	}
+}
@@ -1,91 +1,244 @@
 package permute
 
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"faast-go/internal/wordlist"
+)
+
+// Attack modes decide how the lists are combined into permutations.
+const (
+	// ClusterBomb tries every combination of the lists.
+	ClusterBomb = "clusterbomb"
+	// Pitchfork walks the lists together line by line.
+	Pitchfork = "pitchfork"
+	// BatteringRam places every word of the first list in all positions.
+	BatteringRam = "batteringram"
+	// Sniper goes through one list at a time, with the other positions at
+	// their default values.
+	Sniper = "sniper"
+)
+
+// Permutation is a permutation together with its index.
+type Permutation struct {
+	Index  int
+	Values []string
+}
+
+// Range is the half-open range of permutation indices [Start, End).
+type Range struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// PermutationIterator walks a range of the permutation space. Every
+// permutation has an index in [0, Total()), so the iterator can jump to any
+// permutation and iterate any sub-range of the space.
 type PermutationIterator struct {
-	lists    [][]string
+	lists    []wordlist.List
+	mode     string
+	total    int
+	defaults []string
+	// indices holds the line of every list used by the next permutation, or
+	// -1 when a list is at its default value.
 	indices  []int
+	index    int
+	end      int
 	finished bool
+	err      error
+}
+
+func NewPermutationIterator(lists []wordlist.List) (*PermutationIterator, error) {
+	return NewModeIterator(lists, ClusterBomb, nil)
+}
+
+// NewModeIterator iterates the lists in the given attack mode. defaults are
+// only used by Sniper, and a missing default is an empty string. It fails
+// when the permutations cannot all be indexed.
+func NewModeIterator(lists []wordlist.List, mode string, defaults []string) (*PermutationIterator, error) {
+	total, err := Total(lists, mode)
+	if err != nil {
+		return nil, err
+	}
+	pi := &PermutationIterator{
+		lists:    lists,
+		mode:     mode,
+		total:    total,
+		defaults: make([]string, len(lists)),
+		indices:  make([]int, len(lists)),
+	}
+	copy(pi.defaults, defaults)
+	pi.SetRange(0, pi.total)
+	return pi, nil
+}
+
+func (pi *PermutationIterator) Total() int {
+	return pi.total
+}
+
+// Index is the index of the permutation Next returns.
+func (pi *PermutationIterator) Index() int {
+	return pi.index
 }
 
-func NewPermutationIterator(lists [][]string) *PermutationIterator {
-	return &PermutationIterator{
-		lists:   lists,
-		indices: make([]int, len(lists)),
+// SetRange limits the iterator to the permutations in [start, end) and
+// seeks to start.
+func (pi *PermutationIterator) SetRange(start, end int) {
+	pi.end = min(end, pi.total)
+	pi.Seek(start)
+}
+
+// Seek moves the iterator to the permutation with the given index.
+func (pi *PermutationIterator) Seek(index int) {
+	pi.index = index
+	pi.finished = index < 0 || index >= pi.end
+	if !pi.finished {
+		pi.decode(index, pi.indices)
 	}
 }
 
+// Next returns the next permutation. It stops early when a wordlist could
+// not be read, see Err.
 func (pi *PermutationIterator) Next() ([]string, bool) {
-	if pi.finished {
+	if pi.finished || pi.err != nil {
 		return nil, false
 	}
+	result := pi.values(pi.indices)
+	if err := wordlist.Lists(pi.lists).Err(); err != nil {
+		pi.err = err
+		return nil, false
+	}
+	pi.Seek(pi.index + 1)
+	return result, true
+}
+
+// Err is the error that stopped the iterator, if a wordlist could not be
+// read.
+func (pi *PermutationIterator) Err() error {
+	return pi.err
+}
+
+func (pi *PermutationIterator) values(indices []int) []string {
 	result := make([]string, len(pi.lists))
-	for i, list := range pi.lists {
-		result[i] = list[pi.indices[i]]
+	for i, line := range indices {
+		switch {
+		case line < 0:
+			result[i] = pi.defaults[i]
+		case pi.mode == BatteringRam:
+			// every position takes its word from the first list
+			result[i] = pi.lists[0].Get(line)
+		default:
+			result[i] = pi.lists[i].Get(line)
+		}
 	}
-	// Update indices
-	for i := len(pi.indices) - 1; i >= 0; i-- {
-		pi.indices[i]++
-		if pi.indices[i] < len(pi.lists[i]) {
-			break
+	return result
+}
+
+// decode turns a permutation index into the line of every list.
+func (pi *PermutationIterator) decode(index int, indices []int) {
+	switch pi.mode {
+	case Pitchfork, BatteringRam:
+		for i := range indices {
+			indices[i] = index
+		}
+	case Sniper:
+		for i, list := range pi.lists {
+			indices[i] = -1
+			if index >= 0 && index < list.Len() {
+				indices[i] = index
+			}
+			index -= list.Len()
 		}
-		pi.indices[i] = 0
-		if i == 0 {
-			pi.finished = true
+	default:
+		// mixed radix, with the last list changing fastest
+		for i := len(pi.lists) - 1; i >= 0; i-- {
+			indices[i] = index % pi.lists[i].Len()
+			index /= pi.lists[i].Len()
 		}
 	}
-	return result, true
 }
 
-func ShardLists(listOfLists [][]string, n int, numOfShards int) [][][]string {
-	if len(listOfLists) == 0 || n < 0 || n >= len(listOfLists) || numOfShards <= 0 {
-		return [][][]string{}
-	}
-	// Determine the size of each shard for the nth list
-	nthListLen := len(listOfLists[n])
-	shardSize := (nthListLen + numOfShards - 1) / numOfShards // Round up division
-	// Create the shards
-	shards := make([][][]string, numOfShards)
-	for i := range shards {
-		shards[i] = make([][]string, len(listOfLists))
+// Total is the number of permutations the lists produce in the given mode.
+// It fails when there are more than an int can index.
+func Total(lists []wordlist.List, mode string) (int, error) {
+	if len(lists) == 0 {
+		return 0, nil
 	}
-	// Fill the shards
-	for i := 0; i < len(listOfLists); i++ {
-		if i == n {
-			// Shard the nth list
-			for j := 0; j < numOfShards; j++ {
-				start := j * shardSize
-				end := start + shardSize
-				if end > nthListLen {
-					end = nthListLen
-				}
-				shards[j][i] = listOfLists[i][start:end]
+	switch mode {
+	case Pitchfork:
+		total := lists[0].Len()
+		for _, list := range lists[1:] {
+			total = min(total, list.Len())
+		}
+		return total, nil
+	case BatteringRam:
+		return lists[0].Len(), nil
+	case Sniper:
+		total := 0
+		for _, list := range lists {
+			if total > math.MaxInt-list.Len() {
+				return 0, fmt.Errorf("the lists have more than %d permutations", math.MaxInt)
+			}
+			total += list.Len()
+		}
+		return total, nil
+	default:
+		total := 1
+		for _, list := range lists {
+			if list.Len() == 0 {
+				return 0, nil
 			}
-		} else {
-			// For other lists, just assign the reference to all shards
-			for j := 0; j < numOfShards; j++ {
-				shards[j][i] = listOfLists[i]
+			if total > math.MaxInt/list.Len() {
+				return 0, fmt.Errorf("the lists have more than %d permutations", math.MaxInt)
 			}
+			total *= list.Len()
 		}
+		return total, nil
 	}
-	return shards
 }
 
-func CalculateTotalPermutations(shardedLists [][][]string) int {
-	if len(shardedLists) == 0 || len(shardedLists[0]) == 0 {
-		return 0
+// ShardRange splits the indices [0, total) into numShards contiguous ranges
+// whose sizes differ by at most one, and returns the range of shard
+// shardIndex.
+func ShardRange(total int, shardIndex int, numShards int) (int, int) {
+	if numShards <= 0 || shardIndex < 0 || shardIndex >= numShards {
+		return 0, 0
 	}
-	total := 1
-	for _, list := range shardedLists[0] {
-		total *= len(list)
+	size, remainder := total/numShards, total%numShards
+	start := shardIndex*size + min(shardIndex, remainder)
+	end := start + size
+	if shardIndex < remainder {
+		end++
 	}
-	return total * len(shardedLists)
+	return start, end
 }
 
-func IteratePermutations(permuter *PermutationIterator, results chan<- []string) {
+// IteratePermutations sends every permutation to results until the iterator
+// is exhausted, a wordlist cannot be read or ctx is cancelled.
+func IteratePermutations(ctx context.Context, permuter *PermutationIterator, results chan<- Permutation) {
 	for {
+		index := permuter.Index()
 		perm, ok := permuter.Next()
 		if !ok {
 			return
 		}
-		results <- perm
+		select {
+		case results <- Permutation{Index: index, Values: perm}:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// IterateRanges sends the permutations of every range in turn.
+func IterateRanges(ctx context.Context, permuter *PermutationIterator, ranges []Range, results chan<- Permutation) {
+	for _, r := range ranges {
+		if ctx.Err() != nil || permuter.Err() != nil {
+			return
+		}
+		permuter.SetRange(r.Start, r.End)
+		IteratePermutations(ctx, permuter, results)
 	}
 }
@@ -1,14 +1,45 @@
 package permute
 
 import (
+	"context"
+	"errors"
 	"reflect"
 	"testing"
 	"time"
+
+	"faast-go/internal/wordlist"
 )
 
+func fromSlices(lists [][]string) []wordlist.List {
+	result := make([]wordlist.List, len(lists))
+	for i, list := range lists {
+		result[i] = wordlist.Slice(list)
+	}
+	return result
+}
+
+func newIterator(t *testing.T, lists []wordlist.List, mode string, defaults []string) *PermutationIterator {
+	t.Helper()
+	pi, err := NewModeIterator(lists, mode, defaults)
+	if err != nil {
+		t.Fatalf("NewModeIterator failed: %v", err)
+	}
+	return pi
+}
+
+// at returns the permutation with the given index.
+func at(pi *PermutationIterator, index int) []string {
+	indices := make([]int, len(pi.lists))
+	pi.decode(index, indices)
+	return pi.values(indices)
+}
+
 func TestNewPermutationIterator(t *testing.T) {
-	lists := [][]string{{"a", "b"}, {"1", "2"}}
-	pi := NewPermutationIterator(lists)
+	lists := fromSlices([][]string{{"a", "b"}, {"1", "2"}})
+	pi, err := NewPermutationIterator(lists)
+	if err != nil {
+		t.Fatalf("NewPermutationIterator failed: %v", err)
+	}
 
 	if pi == nil {
 		t.Fatal("NewPermutationIterator returned nil")
@@ -34,8 +65,8 @@ func TestNewPermutationIterator(t *testing.T) {
 }
 
 func TestPermutationIterator_Next(t *testing.T) {
-	lists := [][]string{{"a", "b"}, {"1", "2"}}
-	pi := NewPermutationIterator(lists)
+	lists := fromSlices([][]string{{"a", "b"}, {"1", "2"}})
+	pi := newIterator(t, lists, ClusterBomb, nil)
 
 	expected := [][]string{
 		{"a", "1"},
@@ -61,104 +92,281 @@ func TestPermutationIterator_Next(t *testing.T) {
 	}
 }
 
-func TestShardLists(t *testing.T) {
+func TestModeIterator(t *testing.T) {
+	lists := fromSlices([][]string{{"alice", "bob", "carol"}, {"pw1", "pw2"}})
 	tests := []struct {
-		name        string
-		lists       [][]string
-		n           int
-		numOfShards int
-		want        [][][]string
+		name     string
+		mode     string
+		defaults []string
+		want     [][]string
 	}{
 		{
-			name:        "Basic sharding",
-			lists:       [][]string{{"a", "b"}, {"1", "2", "3", "4"}},
-			n:           1,
-			numOfShards: 2,
-			want: [][][]string{
-				{{"a", "b"}, {"1", "2"}},
-				{{"a", "b"}, {"3", "4"}},
+			name: "Cluster bomb",
+			mode: ClusterBomb,
+			want: [][]string{
+				{"alice", "pw1"}, {"alice", "pw2"},
+				{"bob", "pw1"}, {"bob", "pw2"},
+				{"carol", "pw1"}, {"carol", "pw2"},
 			},
 		},
 		{
-			name:        "Empty input",
-			lists:       [][]string{},
-			n:           0,
-			numOfShards: 2,
-			want:        [][][]string{},
+			name: "Pitchfork stops at the shortest list",
+			mode: Pitchfork,
+			want: [][]string{{"alice", "pw1"}, {"bob", "pw2"}},
 		},
 		{
-			name:        "Invalid n",
-			lists:       [][]string{{"a", "b"}, {"1", "2"}},
-			n:           2,
-			numOfShards: 2,
-			want:        [][][]string{},
+			name: "Battering ram",
+			mode: BatteringRam,
+			want: [][]string{{"alice", "alice"}, {"bob", "bob"}, {"carol", "carol"}},
 		},
 		{
-			name:        "Invalid numOfShards",
-			lists:       [][]string{{"a", "b"}, {"1", "2"}},
-			n:           0,
-			numOfShards: 0,
-			want:        [][][]string{},
+			name:     "Sniper",
+			mode:     Sniper,
+			defaults: []string{"admin", "password"},
+			want: [][]string{
+				{"alice", "password"}, {"bob", "password"}, {"carol", "password"},
+				{"admin", "pw1"}, {"admin", "pw2"},
+			},
+		},
+		{
+			name: "Sniper without defaults",
+			mode: Sniper,
+			want: [][]string{
+				{"alice", ""}, {"bob", ""}, {"carol", ""},
+				{"", "pw1"}, {"", "pw2"},
+			},
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := ShardLists(tt.lists, tt.n, tt.numOfShards)
+			pi := newIterator(t, lists, tt.mode, tt.defaults)
+			var got [][]string
+			for {
+				perm, ok := pi.Next()
+				if !ok {
+					break
+				}
+				got = append(got, perm)
+			}
 			if !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("ShardLists() = %v, want %v", got, tt.want)
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+			if total, err := Total(lists, tt.mode); err != nil || total != len(tt.want) {
+				t.Errorf("Total() = %d, %v, want %d", total, err, len(tt.want))
+			}
+		})
+	}
+}
+
+func TestModeIterator_EmptyLists(t *testing.T) {
+	for _, mode := range []string{ClusterBomb, Pitchfork, BatteringRam, Sniper} {
+		pi := newIterator(t, fromSlices([][]string{{}, {"a"}}), mode, nil)
+		perm, ok := pi.Next()
+		if mode == Sniper {
+			// sniper skips the empty list and still attacks the second one
+			if !ok || !reflect.DeepEqual(perm, []string{"", "a"}) {
+				t.Errorf("%s: expected ([ a], true), got (%v, %v)", mode, perm, ok)
+			}
+			continue
+		}
+		if ok {
+			t.Errorf("%s: expected no permutations, got %v", mode, perm)
+		}
+	}
+}
+
+func TestShardRange(t *testing.T) {
+	tests := []struct {
+		name       string
+		total      int
+		shardIndex int
+		numShards  int
+		wantStart  int
+		wantEnd    int
+	}{
+		{name: "Single shard", total: 10, shardIndex: 0, numShards: 1, wantStart: 0, wantEnd: 10},
+		{name: "Even split", total: 10, shardIndex: 1, numShards: 2, wantStart: 5, wantEnd: 10},
+		{name: "Uneven first shard", total: 10, shardIndex: 0, numShards: 3, wantStart: 0, wantEnd: 4},
+		{name: "Uneven middle shard", total: 10, shardIndex: 1, numShards: 3, wantStart: 4, wantEnd: 7},
+		{name: "Uneven last shard", total: 10, shardIndex: 2, numShards: 3, wantStart: 7, wantEnd: 10},
+		{name: "More shards than permutations", total: 2, shardIndex: 3, numShards: 4, wantStart: 2, wantEnd: 2},
+		{name: "Invalid shard index", total: 10, shardIndex: 2, numShards: 2, wantStart: 0, wantEnd: 0},
+		{name: "Invalid numShards", total: 10, shardIndex: 0, numShards: 0, wantStart: 0, wantEnd: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end := ShardRange(tt.total, tt.shardIndex, tt.numShards)
+			if start != tt.wantStart || end != tt.wantEnd {
+				t.Errorf("ShardRange() = [%d, %d), want [%d, %d)", start, end, tt.wantStart, tt.wantEnd)
 			}
 		})
 	}
 }
 
-func TestCalculateTotalPermutations(t *testing.T) {
+func TestTotal_Shard(t *testing.T) {
 	tests := []struct {
-		name         string
-		shardedLists [][][]string
-		want         int
+		name       string
+		lists      [][]string
+		mode       string
+		shardIndex int
+		numShards  int
+		want       int
 	}{
 		{
-			name: "Basic calculation",
-			shardedLists: [][][]string{
-				{{"a", "b"}, {"1", "2"}},
-				{{"a", "b"}, {"3", "4"}},
-			},
-			want: 8,
+			name:      "Basic calculation",
+			lists:     [][]string{{"a", "b"}, {"1", "2", "3", "4"}},
+			mode:      ClusterBomb,
+			numShards: 1,
+			want:      8,
 		},
 		{
-			name:         "Empty input",
-			shardedLists: [][][]string{},
-			want:         0,
+			name:       "Uneven shard",
+			lists:      [][]string{{"a", "b", "c"}, {"1"}},
+			mode:       ClusterBomb,
+			shardIndex: 0,
+			numShards:  2,
+			want:       2,
 		},
 		{
-			name: "Single empty list",
-			shardedLists: [][][]string{
-				{{}},
-			},
-			want: 0,
+			name:       "Pitchfork shard",
+			lists:      [][]string{{"a", "b", "c"}, {"1", "2", "3"}},
+			mode:       Pitchfork,
+			shardIndex: 1,
+			numShards:  2,
+			want:       1,
+		},
+		{
+			name:      "Empty input",
+			lists:     [][]string{},
+			numShards: 1,
+			want:      0,
+		},
+		{
+			name:      "Single empty list",
+			lists:     [][]string{{}},
+			numShards: 1,
+			want:      0,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := CalculateTotalPermutations(tt.shardedLists)
-			if got != tt.want {
-				t.Errorf("CalculateTotalPermutations() = %v, want %v", got, tt.want)
+			total, err := Total(fromSlices(tt.lists), tt.mode)
+			if err != nil {
+				t.Fatalf("Total failed: %v", err)
+			}
+			start, end := ShardRange(total, tt.shardIndex, tt.numShards)
+			if got := end - start; got != tt.want {
+				t.Errorf("shard size = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTotal_Rules(t *testing.T) {
+	users, err := wordlist.Mutate(wordlist.Slice{"admin", "root"}, []string{":", "c", "$<1-3>"})
+	if err != nil {
+		t.Fatalf("Mutate failed: %v", err)
+	}
+	lists := []wordlist.List{users, wordlist.Slice{"1", "2"}}
+	if got, err := Total(lists, ClusterBomb); err != nil || got != 20 {
+		t.Errorf("Total() = %v, %v, want 20", got, err)
+	}
+	if got := at(newIterator(t, lists, ClusterBomb, nil), 9); !reflect.DeepEqual(got, []string{"admin3", "2"}) {
+		t.Errorf("at(9) = %v, want [admin3 2]", got)
+	}
+}
+
+func TestTotal_Overflow(t *testing.T) {
+	mask, err := wordlist.NewMask("?a?a?a?a?a?a?a?a", nil)
+	if err != nil {
+		t.Fatalf("NewMask failed: %v", err)
+	}
+	pins, err := wordlist.NewRange("0000-9999", 0)
+	if err != nil {
+		t.Fatalf("NewRange failed: %v", err)
+	}
+	lists := []wordlist.List{mask, pins}
+	if _, err := Total(lists, ClusterBomb); err == nil {
+		t.Error("Total() should have returned an error when the permutations overflow")
+	}
+	if _, err := NewModeIterator(lists, ClusterBomb, nil); err == nil {
+		t.Error("NewModeIterator() should have returned an error when the permutations overflow")
+	}
+	if total, err := Total(lists, Sniper); err != nil || total != mask.Len()+pins.Len() {
+		t.Errorf("Total() = %v, %v, want %v in sniper mode", total, err, mask.Len()+pins.Len())
+	}
+}
+
+func collect(pi *PermutationIterator) [][]string {
+	var got [][]string
+	for {
+		perm, ok := pi.Next()
+		if !ok {
+			return got
+		}
+		got = append(got, perm)
+	}
+}
+
+func TestPermutationIterator_SeekAndAt(t *testing.T) {
+	lists := fromSlices([][]string{{"a", "b", "c"}, {"1", "2"}, {"x", "y"}})
+	for _, mode := range []string{ClusterBomb, Pitchfork, BatteringRam, Sniper} {
+		t.Run(mode, func(t *testing.T) {
+			all := collect(newIterator(t, lists, mode, []string{"A", "1", "X"}))
+			pi := newIterator(t, lists, mode, []string{"A", "1", "X"})
+			if len(all) != pi.Total() {
+				t.Fatalf("iterated %d permutations, Total() = %d", len(all), pi.Total())
+			}
+			for i, want := range all {
+				if got := at(pi, i); !reflect.DeepEqual(got, want) {
+					t.Errorf("at(%d) = %v, want %v", i, got, want)
+				}
+			}
+
+			pi.Seek(pi.Total() - 1)
+			if pi.Index() != pi.Total()-1 {
+				t.Errorf("Index() = %d, want %d", pi.Index(), pi.Total()-1)
+			}
+			if got := collect(pi); !reflect.DeepEqual(got, all[len(all)-1:]) {
+				t.Errorf("after Seek got %v, want %v", got, all[len(all)-1:])
+			}
+		})
+	}
+}
+
+func TestPermutationIterator_Shards(t *testing.T) {
+	lists := fromSlices([][]string{{"a", "b", "c", "d", "e"}, {"1", "2", "3"}})
+	for _, mode := range []string{ClusterBomb, Pitchfork, BatteringRam, Sniper} {
+		t.Run(mode, func(t *testing.T) {
+			all := collect(newIterator(t, lists, mode, nil))
+
+			// every permutation is in exactly one shard, in order
+			var sharded [][]string
+			for shard := 0; shard < 4; shard++ {
+				pi := newIterator(t, lists, mode, nil)
+				start, end := ShardRange(pi.Total(), shard, 4)
+				pi.SetRange(start, end)
+				sharded = append(sharded, collect(pi)...)
+			}
+			if !reflect.DeepEqual(sharded, all) {
+				t.Errorf("shards produced %v, want %v", sharded, all)
 			}
 		})
 	}
 }
 
 func TestIteratePermutations(t *testing.T) {
-	lists := [][]string{{"a", "b"}, {"1", "2"}}
-	pi := NewPermutationIterator(lists)
+	lists := fromSlices([][]string{{"a", "b"}, {"1", "2"}})
+	pi := newIterator(t, lists, ClusterBomb, nil)
 
-	results := make(chan []string)
+	results := make(chan Permutation)
 	done := make(chan struct{})
 
 	go func() {
-		IteratePermutations(pi, results)
+		IteratePermutations(context.Background(), pi, results)
 		close(results)
 	}()
 
@@ -176,8 +384,8 @@ func TestIteratePermutations(t *testing.T) {
 				t.Errorf("Channel closed before receiving all expected results. Got %d out of %d", i, len(expected))
 				break
 			}
-			if !reflect.DeepEqual(result, exp) {
-				t.Errorf("IteratePermutations() yielded %v, want %v", result, exp)
+			if !reflect.DeepEqual(result.Values, exp) || result.Index != i {
+				t.Errorf("IteratePermutations() yielded %v, want %v at index %d", result, exp, i)
 			}
 		}
 
@@ -201,3 +409,87 @@ func TestIteratePermutations(t *testing.T) {
 		t.Error("Test timed out")
 	}
 }
+
+func TestIterateRanges(t *testing.T) {
+	lists := fromSlices([][]string{{"a", "b", "c"}, {"1", "2"}})
+	results := make(chan Permutation, 10)
+	IterateRanges(context.Background(), newIterator(t, lists, ClusterBomb, nil), []Range{{Start: 1, End: 2}, {Start: 4, End: 6}}, results)
+	close(results)
+
+	var got []Permutation
+	for perm := range results {
+		got = append(got, perm)
+	}
+	want := []Permutation{
+		{Index: 1, Values: []string{"a", "2"}},
+		{Index: 4, Values: []string{"c", "1"}},
+		{Index: 5, Values: []string{"c", "2"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("IterateRanges() = %v, want %v", got, want)
+	}
+}
+
+// brokenList fails to read any word but its first.
+type brokenList struct {
+	wordlist.Slice
+	err error
+}
+
+func (b *brokenList) Get(i int) string {
+	if i > 0 {
+		b.err = errors.New("read failed")
+		return ""
+	}
+	return b.Slice.Get(i)
+}
+
+func (b *brokenList) Err() error {
+	return b.err
+}
+
+func TestIterateRanges_ReadError(t *testing.T) {
+	lists := []wordlist.List{&brokenList{Slice: wordlist.Slice{"a", "b"}}, wordlist.Slice{"1", "2"}}
+	pi := newIterator(t, lists, ClusterBomb, nil)
+	results := make(chan Permutation, 10)
+	IterateRanges(context.Background(), pi, []Range{{Start: 0, End: 3}, {Start: 3, End: 4}}, results)
+	close(results)
+
+	var got []Permutation
+	for perm := range results {
+		got = append(got, perm)
+	}
+	want := []Permutation{
+		{Index: 0, Values: []string{"a", "1"}},
+		{Index: 1, Values: []string{"a", "2"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("IterateRanges() = %v, want %v", got, want)
+	}
+	if pi.Err() == nil {
+		t.Error("Err() should have returned the read error")
+	}
+}
+
+func TestIteratePermutations_Cancel(t *testing.T) {
+	pi := newIterator(t, fromSlices([][]string{{"a", "b", "c"}, {"1", "2"}}), ClusterBomb, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	results := make(chan Permutation)
+	done := make(chan struct{})
+
+	go func() {
+		IterateRanges(ctx, pi, []Range{{Start: 0, End: 3}, {Start: 3, End: 6}}, results)
+		close(done)
+	}()
+
+	if perm := <-results; perm.Index != 0 {
+		t.Errorf("first permutation index = %d, want 0", perm.Index)
+	}
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("IterateRanges() did not return after cancel")
+	}
+}
@@ -0,0 +1,61 @@
+package wordlist
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+var (
+	gzipMagic  = []byte{0x1f, 0x8b}
+	bzip2Magic = []byte("BZh")
+)
+
+// compression is "gzip", "bzip2" or "" for a plain file. It is detected from
+// the first bytes of the file, or from the extension when those are missing,
+// so a broken .gz file fails instead of being read as text.
+func compression(filename string, header []byte) string {
+	switch {
+	case bytes.HasPrefix(header, gzipMagic), strings.HasSuffix(filename, ".gz"):
+		return "gzip"
+	case isBzip2(header), strings.HasSuffix(filename, ".bz2"):
+		return "bzip2"
+	}
+	return ""
+}
+
+// isBzip2 reports whether header is the bzip2 magic followed by a block size
+// from 1 to 9, so a plain list whose first word starts with "BZh" is not
+// taken for one.
+func isBzip2(header []byte) bool {
+	return len(header) > len(bzip2Magic) && bytes.HasPrefix(header, bzip2Magic) &&
+		header[len(bzip2Magic)] >= '1' && header[len(bzip2Magic)] <= '9'
+}
+
+// rewind returns a reader of the content of file from the start, decompressed
+// when it is compressed.
+func rewind(file *os.File, kind string) (io.Reader, error) {
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	switch kind {
+	case "gzip":
+		return gzip.NewReader(file)
+	case "bzip2":
+		return bzip2.NewReader(file), nil
+	}
+	return file, nil
+}
+
+func detect(file *os.File) (string, error) {
+	header := make([]byte, 4)
+	n, err := file.ReadAt(header, 0)
+	if err != nil && err != io.EOF {
+		return "", fmt.Errorf("error reading wordlist file %s: %w", file.Name(), err)
+	}
+	return compression(file.Name(), header[:n]), nil
+}
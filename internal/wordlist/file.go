@@ -0,0 +1,123 @@
+package wordlist
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sync"
+)
+
+// File is a wordlist file with an index of where every line starts. The
+// index takes 4 bytes per line for files under 4GiB, 8 bytes otherwise, and
+// lines are read from disk on demand. A compressed file cannot be read at an
+// offset, so it is decompressed into memory once and indexed the same way.
+type File struct {
+	name string
+	file *os.File
+	data []byte
+	// small or large holds the offset of every line, followed by the size
+	// of the content.
+	small []uint32
+	large []int64
+
+	mu        sync.Mutex
+	last      int
+	lastValue string
+	err       error
+}
+
+func Open(filename string) (*File, error) {
+	file, size, kind, err := openFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &File{name: filename, file: file, last: -1}
+	var content io.Reader = file
+	if kind != "" {
+		f.data, err = decompressAll(file, kind)
+		file.Close()
+		f.file = nil
+		if err != nil {
+			return nil, fmt.Errorf("error reading wordlist file %s: %w", filename, err)
+		}
+		size = int64(len(f.data))
+		content = bytes.NewReader(f.data)
+	}
+
+	record := func(line int, offset int64) { f.large = append(f.large, offset) }
+	if size <= math.MaxUint32 {
+		record = func(line int, offset int64) { f.small = append(f.small, uint32(offset)) }
+	}
+	if _, err := scanLines(content, record); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("error reading wordlist file %s: %w", filename, err)
+	}
+	record(0, size)
+	return f, nil
+}
+
+func decompressAll(file *os.File, kind string) ([]byte, error) {
+	r, err := rewind(file, kind)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+func (f *File) offset(i int) int64 {
+	if f.large != nil {
+		return f.large[i]
+	}
+	return int64(f.small[i])
+}
+
+func (f *File) Len() int {
+	return max(len(f.small), len(f.large)) - 1
+}
+
+// Get reads line i. The last line read is kept, as consecutive permutations
+// mostly share their values. It returns an empty string once a read failed,
+// see Err.
+func (f *File) Get(i int) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return ""
+	}
+	if i == f.last {
+		return f.lastValue
+	}
+
+	start, end := f.offset(i), f.offset(i+1)
+	var line []byte
+	if f.data != nil {
+		line = f.data[start:end]
+	} else {
+		line = make([]byte, end-start)
+		if _, err := f.file.ReadAt(line, start); err != nil && err != io.EOF {
+			f.err = fmt.Errorf("error reading wordlist file %s: %w", f.name, err)
+			return ""
+		}
+	}
+	f.last, f.lastValue = i, trimLine(line)
+	return f.lastValue
+}
+
+// Err is the error of the first read that failed. The file was indexed when
+// it was opened, so this only happens if it changed or disappeared while
+// running.
+func (f *File) Err() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.err
+}
+
+func (f *File) Close() error {
+	if f.file == nil {
+		return nil
+	}
+	return f.file.Close()
+}
@@ -0,0 +1,216 @@
+package wordlist
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"faast-go/internal/config"
+)
+
+// Range generates the numbers from one end of a range to the other.
+type Range struct {
+	start int
+	step  int
+	count int
+	width int
+}
+
+// NewRange parses a range such as "0000-9999". Values are padded with zeros
+// to the width of the start, and a start above the end counts down.
+func NewRange(spec string, step int) (*Range, error) {
+	from, to, ok := strings.Cut(spec, "-")
+	start, err1 := strconv.Atoi(from)
+	end, err2 := strconv.Atoi(to)
+	if !ok || err1 != nil || err2 != nil || start < 0 || end < 0 {
+		return nil, fmt.Errorf("invalid range %q", spec)
+	}
+	step = max(step, 1)
+	r := &Range{start: start, step: step, width: len(from)}
+	if end < start {
+		r.step = -step
+	}
+	r.count = (end-start)/r.step + 1
+	return r, nil
+}
+
+func (r *Range) Len() int {
+	return r.count
+}
+
+func (r *Range) Get(i int) string {
+	return fmt.Sprintf("%0*d", r.width, r.start+i*r.step)
+}
+
+// charsets are the built-in hashcat charsets.
+var charsets = map[byte]string{
+	'l': "abcdefghijklmnopqrstuvwxyz",
+	'u': "ABCDEFGHIJKLMNOPQRSTUVWXYZ",
+	'd': "0123456789",
+	'h': "0123456789abcdef",
+	'H': "0123456789ABCDEF",
+	's': " !\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~",
+	'a': "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789 !\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~",
+}
+
+// Mask generates every word matching a hashcat mask, with the last position
+// changing fastest.
+type Mask struct {
+	positions []string
+	count     int
+}
+
+// NewMask parses a mask. ?l, ?u, ?d, ?h, ?H, ?s and ?a are the hashcat
+// charsets, ?1 to ?4 the custom charsets, which can use the built-in ones,
+// and ?? is a question mark. Any other character stands for itself.
+func NewMask(mask string, named map[string]string) (*Mask, error) {
+	custom := make(map[byte]string, len(named))
+	for name, charset := range named {
+		if len(name) != 1 || name[0] < '1' || name[0] > '4' {
+			return nil, fmt.Errorf("invalid charset name %q, use 1 to 4", name)
+		}
+		expanded, err := expandCharset(charset, nil)
+		if err != nil {
+			return nil, fmt.Errorf("invalid charset %s: %w", name, err)
+		}
+		custom[name[0]] = expanded
+	}
+
+	positions, err := maskPositions(mask, custom)
+	if err != nil {
+		return nil, fmt.Errorf("invalid mask %q: %w", mask, err)
+	}
+	m := &Mask{positions: positions, count: 1}
+	for _, position := range positions {
+		if m.count > math.MaxInt/len(position) {
+			return nil, fmt.Errorf("mask %q has too many values", mask)
+		}
+		m.count *= len(position)
+	}
+	return m, nil
+}
+
+func maskPositions(mask string, custom map[byte]string) ([]string, error) {
+	var positions []string
+	for i := 0; i < len(mask); i++ {
+		if mask[i] != '?' {
+			positions = append(positions, mask[i:i+1])
+			continue
+		}
+		if i+1 == len(mask) {
+			return nil, fmt.Errorf("missing charset after the last ?")
+		}
+		i++
+		switch name := mask[i]; {
+		case name == '?':
+			positions = append(positions, "?")
+		case charsets[name] != "":
+			positions = append(positions, charsets[name])
+		case custom[name] != "":
+			positions = append(positions, custom[name])
+		default:
+			return nil, fmt.Errorf("unknown charset ?%c", name)
+		}
+	}
+	return positions, nil
+}
+
+// expandCharset replaces the built-in charsets in a custom charset and
+// removes duplicate characters.
+func expandCharset(charset string, custom map[byte]string) (string, error) {
+	positions, err := maskPositions(charset, custom)
+	if err != nil {
+		return "", err
+	}
+	seen := make(map[byte]bool)
+	var expanded []byte
+	for _, c := range []byte(strings.Join(positions, "")) {
+		if !seen[c] {
+			seen[c] = true
+			expanded = append(expanded, c)
+		}
+	}
+	if len(expanded) == 0 {
+		return "", fmt.Errorf("empty charset")
+	}
+	return string(expanded), nil
+}
+
+func (m *Mask) Len() int {
+	return m.count
+}
+
+func (m *Mask) Get(i int) string {
+	word := make([]byte, len(m.positions))
+	for p := len(m.positions) - 1; p >= 0; p-- {
+		charset := m.positions[p]
+		word[p] = charset[i%len(charset)]
+		i /= len(charset)
+	}
+	return string(word)
+}
+
+// Dates generates dates at a fixed interval.
+type Dates struct {
+	from   time.Time
+	days   int
+	step   time.Duration
+	count  int
+	format string
+}
+
+// dateLayouts are the layouts accepted for the from and to of a date range.
+var dateLayouts = []string{"2006-01-02", "2006-01-02 15:04", "2006-01-02 15:04:05", time.RFC3339}
+
+func parseDate(value string) (time.Time, error) {
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q, use YYYY-MM-DD", value)
+}
+
+// NewDates generates the dates of the config. A step in days ("7d") moves by
+// calendar days, any other step is a duration.
+func NewDates(settings config.DatesConfig) (*Dates, error) {
+	from, err := parseDate(settings.From)
+	if err != nil {
+		return nil, err
+	}
+	to, err := parseDate(settings.To)
+	if err != nil {
+		return nil, err
+	}
+	if to.Before(from) {
+		return nil, fmt.Errorf("dates from %s is after to %s", settings.From, settings.To)
+	}
+
+	d := &Dates{from: from, format: settings.Format}
+	step := settings.Step
+	if days, ok := strings.CutSuffix(step, "d"); ok {
+		if d.days, err = strconv.Atoi(days); err != nil || d.days <= 0 {
+			return nil, fmt.Errorf("invalid dates step %q", step)
+		}
+		d.count = int(to.Sub(from)/(24*time.Hour))/d.days + 1
+		return d, nil
+	}
+	if d.step, err = time.ParseDuration(step); err != nil || d.step <= 0 {
+		return nil, fmt.Errorf("invalid dates step %q", step)
+	}
+	d.count = int(to.Sub(from)/d.step) + 1
+	return d, nil
+}
+
+func (d *Dates) Len() int {
+	return d.count
+}
+
+func (d *Dates) Get(i int) string {
+	if d.days > 0 {
+		return d.from.AddDate(0, 0, i*d.days).Format(d.format)
+	}
+	return d.from.Add(time.Duration(i) * d.step).Format(d.format)
+}
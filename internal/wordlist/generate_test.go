@@ -0,0 +1,141 @@
+package wordlist
+
+import (
+	"reflect"
+	"testing"
+
+	"faast-go/internal/config"
+)
+
+func TestNewRange(t *testing.T) {
+	tests := []struct {
+		spec    string
+		step    int
+		want    []string
+		wantErr bool
+	}{
+		{spec: "1-5", want: []string{"1", "2", "3", "4", "5"}},
+		{spec: "0000-0003", want: []string{"0000", "0001", "0002", "0003"}},
+		{spec: "00-10", step: 5, want: []string{"00", "05", "10"}},
+		{spec: "0-9", step: 4, want: []string{"0", "4", "8"}},
+		{spec: "10-7", want: []string{"10", "09", "08", "07"}},
+		{spec: "100-100", want: []string{"100"}},
+		{spec: "1", wantErr: true},
+		{spec: "a-z", wantErr: true},
+		{spec: "-5-5", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			r, err := NewRange(tt.spec, tt.step)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewRange() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := words(r); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewMask(t *testing.T) {
+	tests := []struct {
+		name     string
+		mask     string
+		charsets map[string]string
+		len      int
+		want     map[int]string
+		wantErr  bool
+	}{
+		{name: "Digits", mask: "?d?d?d?d", len: 10000, want: map[int]string{0: "0000", 42: "0042", 9999: "9999"}},
+		{name: "Literals", mask: "PIN-?d", len: 10, want: map[int]string{0: "PIN-0", 9: "PIN-9"}},
+		{name: "Upper lower", mask: "?u?l", len: 676, want: map[int]string{0: "Aa", 27: "Bb", 675: "Zz"}},
+		{name: "Question mark", mask: "??", len: 1, want: map[int]string{0: "?"}},
+		{name: "Hex", mask: "?h?H", len: 256, want: map[int]string{255: "fF"}},
+		{name: "Custom", mask: "?1?d", charsets: map[string]string{"1": "ab?u"}, len: 280, want: map[int]string{0: "a0", 279: "Z9"}},
+		{name: "Duplicates removed", mask: "?1", charsets: map[string]string{"1": "aab"}, len: 2, want: map[int]string{1: "b"}},
+		{name: "Unknown charset", mask: "?x", wantErr: true},
+		{name: "Undefined custom charset", mask: "?2", wantErr: true},
+		{name: "Trailing question mark", mask: "?d?", wantErr: true},
+		{name: "Invalid charset name", mask: "?d", charsets: map[string]string{"5": "ab"}, wantErr: true},
+		{name: "Too many values", mask: "?a?a?a?a?a?a?a?a?a?a?a", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := NewMask(tt.mask, tt.charsets)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewMask() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if m.Len() != tt.len {
+				t.Errorf("Len() = %d, want %d", m.Len(), tt.len)
+			}
+			for i, want := range tt.want {
+				if got := m.Get(i); got != want {
+					t.Errorf("Get(%d) = %q, want %q", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestNewDates(t *testing.T) {
+	tests := []struct {
+		name    string
+		dates   config.DatesConfig
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:  "Days",
+			dates: config.DatesConfig{From: "2024-02-27", To: "2024-03-01", Step: "1d", Format: "2006-01-02"},
+			want:  []string{"2024-02-27", "2024-02-28", "2024-02-29", "2024-03-01"},
+		},
+		{
+			name:  "Weeks with a format",
+			dates: config.DatesConfig{From: "2024-01-01", To: "2024-01-20", Step: "7d", Format: "02012006"},
+			want:  []string{"01012024", "08012024", "15012024"},
+		},
+		{
+			name:  "Hours",
+			dates: config.DatesConfig{From: "2024-01-01 22:00", To: "2024-01-02 01:30", Step: "1h", Format: "2006-01-02T15"},
+			want:  []string{"2024-01-01T22", "2024-01-01T23", "2024-01-02T00", "2024-01-02T01"},
+		},
+		{
+			name:    "From after to",
+			dates:   config.DatesConfig{From: "2024-01-02", To: "2024-01-01", Step: "1d"},
+			wantErr: true,
+		},
+		{
+			name:    "Invalid date",
+			dates:   config.DatesConfig{From: "01/02/2024", To: "2024-01-01", Step: "1d"},
+			wantErr: true,
+		},
+		{
+			name:    "Invalid step",
+			dates:   config.DatesConfig{From: "2024-01-01", To: "2024-01-02", Step: "0d"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := NewDates(tt.dates)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewDates() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := words(d); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
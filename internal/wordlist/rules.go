@@ -0,0 +1,385 @@
+package wordlist
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// rule is a hashcat rule: functions applied to a word one after the other.
+type rule []func(word []byte) []byte
+
+func (r rule) apply(word string) string {
+	b := []byte(word)
+	for _, fn := range r {
+		b = fn(b)
+	}
+	return string(b)
+}
+
+// Mutated is a list whose every word is passed through every rule. The
+// mutations are computed when a word is read, and all mutations of a word
+// come before those of the next word.
+type Mutated struct {
+	list  List
+	rules []rule
+}
+
+// Mutate applies the hashcat-style rules to every word of list. Without
+// rules the list is returned as it is.
+func Mutate(list List, rules []string) (List, error) {
+	if len(rules) == 0 {
+		return list, nil
+	}
+	m := &Mutated{list: list}
+	for _, line := range rules {
+		parsed, err := ParseRule(line)
+		if err != nil {
+			return nil, err
+		}
+		m.rules = append(m.rules, parsed...)
+	}
+	if list.Len() > math.MaxInt/len(m.rules) {
+		return nil, fmt.Errorf("%d rules on %d words make too many values", len(m.rules), list.Len())
+	}
+	return m, nil
+}
+
+func (m *Mutated) Len() int {
+	return m.list.Len() * len(m.rules)
+}
+
+func (m *Mutated) Get(i int) string {
+	return m.rules[i%len(m.rules)].apply(m.list.Get(i / len(m.rules)))
+}
+
+func (m *Mutated) Err() error {
+	if list, ok := m.list.(failingList); ok {
+		return list.Err()
+	}
+	return nil
+}
+
+func (m *Mutated) Close() error {
+	if closer, ok := m.list.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// ParseRule parses one line of hashcat rule functions, such as "c $1 $!" or
+// "sa@ so0". A "$<from-to>" or "^<from-to>" appends or prepends every number
+// of the range, padded to the width of from, so the line turns into one rule
+// per number.
+func ParseRule(line string) ([]rule, error) {
+	rules := []rule{{}}
+	p := &ruleParser{line: line}
+	for !p.done() {
+		fns, err := p.next()
+		if err != nil {
+			return nil, fmt.Errorf("invalid rule %q: %w", line, err)
+		}
+		if fns == nil {
+			continue
+		}
+		expanded := make([]rule, 0, len(rules)*len(fns))
+		for _, r := range rules {
+			for _, fn := range fns {
+				expanded = append(expanded, append(r[:len(r):len(r)], fn))
+			}
+		}
+		rules = expanded
+	}
+	return rules, nil
+}
+
+type ruleParser struct {
+	line string
+	pos  int
+}
+
+func (p *ruleParser) done() bool {
+	return p.pos >= len(p.line)
+}
+
+func (p *ruleParser) char() (byte, error) {
+	if p.done() {
+		return 0, fmt.Errorf("missing argument at the end")
+	}
+	c := p.line[p.pos]
+	p.pos++
+	return c, nil
+}
+
+// position reads a position argument, 0-9 then A-Z for 10-35.
+func (p *ruleParser) position() (int, error) {
+	c, err := p.char()
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case c >= '0' && c <= '9':
+		return int(c - '0'), nil
+	case c >= 'A' && c <= 'Z':
+		return int(c-'A') + 10, nil
+	}
+	return 0, fmt.Errorf("invalid position %q", c)
+}
+
+// affixes reads the argument of $ and ^: a character, or a <from-to> range.
+func (p *ruleParser) affixes() ([]string, error) {
+	rest := p.line[p.pos:]
+	if strings.HasPrefix(rest, "<") {
+		if end := strings.IndexByte(rest, '>'); end > 0 {
+			from, to, ok := strings.Cut(rest[1:end], "-")
+			first, err1 := strconv.Atoi(from)
+			last, err2 := strconv.Atoi(to)
+			if ok && err1 == nil && err2 == nil && first >= 0 && first <= last {
+				p.pos += end + 1
+				var affixes []string
+				for n := first; n <= last; n++ {
+					affixes = append(affixes, fmt.Sprintf("%0*d", len(from), n))
+				}
+				return affixes, nil
+			}
+		}
+	}
+	c, err := p.char()
+	if err != nil {
+		return nil, err
+	}
+	return []string{string(c)}, nil
+}
+
+// next parses one function. It returns several when the function takes a
+// range, and none for spaces.
+func (p *ruleParser) next() ([]func([]byte) []byte, error) {
+	name, _ := p.char()
+	one := func(fn func([]byte) []byte) ([]func([]byte) []byte, error) {
+		return []func([]byte) []byte{fn}, nil
+	}
+
+	switch name {
+	case ' ':
+		return nil, nil
+	case ':':
+		return one(func(w []byte) []byte { return w })
+	case 'l':
+		return one(bytes.ToLower)
+	case 'u':
+		return one(bytes.ToUpper)
+	case 'c':
+		return one(func(w []byte) []byte { return capitalize(bytes.ToLower(w), true) })
+	case 'C':
+		return one(func(w []byte) []byte { return capitalize(bytes.ToUpper(w), false) })
+	case 't':
+		return one(func(w []byte) []byte {
+			for i := range w {
+				w[i] = toggle(w[i])
+			}
+			return w
+		})
+	case 'E':
+		return one(func(w []byte) []byte {
+			w = bytes.ToLower(w)
+			for i := range w {
+				if i == 0 || w[i-1] == ' ' {
+					w[i] = upper(w[i])
+				}
+			}
+			return w
+		})
+	case 'r':
+		return one(reverse)
+	case 'd':
+		return one(func(w []byte) []byte { return append(w, w...) })
+	case 'f':
+		return one(func(w []byte) []byte { return append(w, reverse(bytes.Clone(w))...) })
+	case 'q':
+		return one(func(w []byte) []byte {
+			doubled := make([]byte, 0, 2*len(w))
+			for _, c := range w {
+				doubled = append(doubled, c, c)
+			}
+			return doubled
+		})
+	case '{':
+		return one(func(w []byte) []byte {
+			if len(w) == 0 {
+				return w
+			}
+			return append(w[1:], w[0])
+		})
+	case '}':
+		return one(func(w []byte) []byte {
+			if len(w) == 0 {
+				return w
+			}
+			return append([]byte{w[len(w)-1]}, w[:len(w)-1]...)
+		})
+	case '[':
+		return one(func(w []byte) []byte {
+			if len(w) == 0 {
+				return w
+			}
+			return w[1:]
+		})
+	case ']':
+		return one(func(w []byte) []byte {
+			if len(w) == 0 {
+				return w
+			}
+			return w[:len(w)-1]
+		})
+	case '$', '^':
+		affixes, err := p.affixes()
+		if err != nil {
+			return nil, err
+		}
+		fns := make([]func([]byte) []byte, len(affixes))
+		for i, affix := range affixes {
+			if name == '$' {
+				fns[i] = func(w []byte) []byte { return append(w, affix...) }
+			} else {
+				fns[i] = func(w []byte) []byte { return append([]byte(affix), w...) }
+			}
+		}
+		return fns, nil
+	case 'T', 'D', '\'', 'p', 'z', 'Z':
+		n, err := p.position()
+		if err != nil {
+			return nil, err
+		}
+		return one(positional(name, n))
+	case 'x', 'O':
+		n, err := p.position()
+		if err != nil {
+			return nil, err
+		}
+		m, err := p.position()
+		if err != nil {
+			return nil, err
+		}
+		return one(func(w []byte) []byte {
+			if n >= len(w) {
+				return w
+			}
+			end := min(n+m, len(w))
+			if name == 'x' {
+				return w[n:end]
+			}
+			return append(w[:n], w[end:]...)
+		})
+	case 'i', 'o':
+		n, err := p.position()
+		if err != nil {
+			return nil, err
+		}
+		c, err := p.char()
+		if err != nil {
+			return nil, err
+		}
+		return one(func(w []byte) []byte {
+			switch {
+			case name == 'o' && n < len(w):
+				w[n] = c
+			case name == 'i' && n <= len(w):
+				w = append(w[:n], append([]byte{c}, w[n:]...)...)
+			}
+			return w
+		})
+	case 's':
+		from, err := p.char()
+		if err != nil {
+			return nil, err
+		}
+		to, err := p.char()
+		if err != nil {
+			return nil, err
+		}
+		return one(func(w []byte) []byte { return bytes.ReplaceAll(w, []byte{from}, []byte{to}) })
+	case '@':
+		c, err := p.char()
+		if err != nil {
+			return nil, err
+		}
+		return one(func(w []byte) []byte { return bytes.ReplaceAll(w, []byte{c}, nil) })
+	}
+	return nil, fmt.Errorf("unknown function %q", name)
+}
+
+// positional builds the functions taking a single position or count. Like
+// every function here, a position past the end of the word leaves it as it
+// is, where hashcat would reject the word, so every word yields a value for
+// every rule.
+func positional(name byte, n int) func([]byte) []byte {
+	return func(w []byte) []byte {
+		switch name {
+		case 'T':
+			if n < len(w) {
+				w[n] = toggle(w[n])
+			}
+		case 'D':
+			if n < len(w) {
+				w = append(w[:n], w[n+1:]...)
+			}
+		case '\'':
+			if n < len(w) {
+				w = w[:n]
+			}
+		case 'p':
+			w = bytes.Repeat(w, n+1)
+		case 'z':
+			if len(w) > 0 {
+				w = append(bytes.Repeat(w[:1], n), w...)
+			}
+		case 'Z':
+			if len(w) > 0 {
+				w = append(w, bytes.Repeat(w[len(w)-1:], n)...)
+			}
+		}
+		return w
+	}
+}
+
+func capitalize(w []byte, up bool) []byte {
+	if len(w) > 0 {
+		if up {
+			w[0] = upper(w[0])
+		} else {
+			w[0] = lower(w[0])
+		}
+	}
+	return w
+}
+
+func reverse(w []byte) []byte {
+	for i, j := 0, len(w)-1; i < j; i, j = i+1, j-1 {
+		w[i], w[j] = w[j], w[i]
+	}
+	return w
+}
+
+func upper(c byte) byte {
+	if c >= 'a' && c <= 'z' {
+		return c - 'a' + 'A'
+	}
+	return c
+}
+
+func lower(c byte) byte {
+	if c >= 'A' && c <= 'Z' {
+		return c - 'A' + 'a'
+	}
+	return c
+}
+
+func toggle(c byte) byte {
+	if c >= 'a' && c <= 'z' {
+		return upper(c)
+	}
+	return lower(c)
+}
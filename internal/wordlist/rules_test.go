@@ -0,0 +1,105 @@
+package wordlist
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseRule(t *testing.T) {
+	tests := []struct {
+		rule    string
+		word    string
+		want    []string
+		wantErr bool
+	}{
+		{rule: ":", word: "Password", want: []string{"Password"}},
+		{rule: "l", word: "PassWord", want: []string{"password"}},
+		{rule: "u", word: "PassWord", want: []string{"PASSWORD"}},
+		{rule: "c", word: "pASSWORD", want: []string{"Password"}},
+		{rule: "C", word: "password", want: []string{"pASSWORD"}},
+		{rule: "t", word: "PassWord1", want: []string{"pASSwORD1"}},
+		{rule: "T0 T4", word: "password", want: []string{"PassWord"}},
+		{rule: "E", word: "hello wORLD", want: []string{"Hello World"}},
+		{rule: "r", word: "abc", want: []string{"cba"}},
+		{rule: "d", word: "abc", want: []string{"abcabc"}},
+		{rule: "p2", word: "ab", want: []string{"ababab"}},
+		{rule: "f", word: "abc", want: []string{"abccba"}},
+		{rule: "q", word: "abc", want: []string{"aabbcc"}},
+		{rule: "{", word: "abc", want: []string{"bca"}},
+		{rule: "}", word: "abc", want: []string{"cab"}},
+		{rule: "[ ]", word: "abcd", want: []string{"bc"}},
+		{rule: "D1", word: "abcd", want: []string{"acd"}},
+		{rule: "'3", word: "password", want: []string{"pas"}},
+		{rule: "x13", word: "password", want: []string{"ass"}},
+		{rule: "O13", word: "password", want: []string{"pword"}},
+		{rule: "i1! o0P", word: "abc", want: []string{"P!bc"}},
+		{rule: "z2 Z1", word: "abc", want: []string{"aaabcc"}},
+		{rule: "sa@ so0 se3", word: "password", want: []string{"p@ssw0rd"}},
+		{rule: "@s", word: "password", want: []string{"paword"}},
+		{rule: "c $1 $!", word: "password", want: []string{"Password1!"}},
+		{rule: "^1 ^2", word: "abc", want: []string{"21abc"}},
+		{rule: "$ ", word: "abc", want: []string{"abc "}},
+		{rule: "c $<2023-2025>", word: "summer", want: []string{"Summer2023", "Summer2024", "Summer2025"}},
+		{rule: "^<00-02>", word: "x", want: []string{"00x", "01x", "02x"}},
+		{rule: "$<0-1> $<0-1>", word: "a", want: []string{"a00", "a01", "a10", "a11"}},
+		{rule: "$<", word: "a", want: []string{"a<"}},
+		// positions past the end leave the word as it is
+		{rule: "T9 D9 '9 x93 o9!", word: "abc", want: []string{"abc"}},
+		{rule: "c", word: "", want: []string{""}},
+		{rule: "K", wantErr: true},
+		{rule: "$", wantErr: true},
+		{rule: "sa", wantErr: true},
+		{rule: "T!", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			rules, err := ParseRule(tt.rule)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseRule() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			var got []string
+			for _, r := range rules {
+				got = append(got, r.apply(tt.word))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMutate(t *testing.T) {
+	list, err := Mutate(Slice{"admin", "summer"}, []string{":", "c $<1-2>"})
+	if err != nil {
+		t.Fatalf("Mutate failed: %v", err)
+	}
+	want := []string{"admin", "Admin1", "Admin2", "summer", "Summer1", "Summer2"}
+	if got := words(list); !reflect.DeepEqual(got, want) {
+		t.Errorf("Got %q, want %q", got, want)
+	}
+	// words are read in any order
+	if got := list.Get(4); got != "Summer1" {
+		t.Errorf("Get(4) = %q, want %q", got, "Summer1")
+	}
+
+	unchanged, err := Mutate(Slice{"a"}, nil)
+	if err != nil || !reflect.DeepEqual(unchanged, Slice{"a"}) {
+		t.Errorf("Mutate without rules = %v, %v", unchanged, err)
+	}
+
+	if _, err := Mutate(Slice{"a"}, []string{"c", "K"}); err == nil {
+		t.Error("Mutate should have returned an error for an unknown function")
+	}
+
+	mask, err := NewMask("?a?a?a?a?a?a?a?a?a", nil)
+	if err != nil {
+		t.Fatalf("NewMask failed: %v", err)
+	}
+	if _, err := Mutate(mask, []string{"$<00-19>"}); err == nil {
+		t.Error("Mutate should have returned an error when the mutations overflow")
+	}
+}
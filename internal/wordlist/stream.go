@@ -0,0 +1,149 @@
+package wordlist
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// streamSpacing is the number of lines between two offsets Stream keeps. A
+// jump costs reading at most that many lines.
+const streamSpacing = 4096
+
+// Stream is a wordlist file read sequentially. Only the offset of every
+// streamSpacing-th line is kept, so memory does not grow with the file, and
+// reading the lines in order never seeks. A compressed file is decompressed
+// as it is read, and going back starts again from the top.
+type Stream struct {
+	file        *os.File
+	compression string
+	lines       int
+	offsets     []int64
+
+	mu        sync.Mutex
+	reader    *bufio.Reader
+	next      int
+	last      int
+	lastValue string
+	err       error
+}
+
+func OpenStream(filename string) (*Stream, error) {
+	file, _, kind, err := openFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Stream{file: file, compression: kind, last: -1}
+	content, err := rewind(file, kind)
+	if err == nil {
+		s.lines, err = scanLines(content, func(line int, offset int64) {
+			if line%streamSpacing == 0 && kind == "" {
+				s.offsets = append(s.offsets, offset)
+			}
+		})
+	}
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("error reading wordlist file %s: %w", filename, err)
+	}
+	s.reader = bufio.NewReaderSize(file, 64<<10)
+	if err := s.seek(0); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("error reading wordlist file %s: %w", filename, err)
+	}
+	return s, nil
+}
+
+func (s *Stream) Len() int {
+	return s.lines
+}
+
+// Get reads line i. It returns an empty string once a read failed, see Err.
+func (s *Stream) Get(i int) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return ""
+	}
+	if i == s.last {
+		return s.lastValue
+	}
+
+	if i < s.next || (s.offsets != nil && i >= s.next+streamSpacing) {
+		if s.fail(s.seek(i)) {
+			return ""
+		}
+	}
+	for s.next < i {
+		if _, err := s.readLine(); s.fail(err) {
+			return ""
+		}
+	}
+	line, err := s.readLine()
+	if s.fail(err) {
+		return ""
+	}
+	s.last, s.lastValue = i, trimLine(line)
+	return s.lastValue
+}
+
+// Err is the error of the first read that failed. The file was scanned when
+// it was opened, so this only happens if it changed or disappeared while
+// running.
+func (s *Stream) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// seek moves the reader to the closest known line at or before line.
+func (s *Stream) seek(line int) error {
+	if s.offsets == nil {
+		content, err := rewind(s.file, s.compression)
+		if err != nil {
+			return err
+		}
+		s.reader.Reset(content)
+		s.next = 0
+		return nil
+	}
+	checkpoint := line / streamSpacing
+	if _, err := s.file.Seek(s.offsets[checkpoint], io.SeekStart); err != nil {
+		return err
+	}
+	s.reader.Reset(s.file)
+	s.next = checkpoint * streamSpacing
+	return nil
+}
+
+func (s *Stream) readLine() ([]byte, error) {
+	var line []byte
+	for {
+		chunk, err := s.reader.ReadSlice('\n')
+		line = append(line, chunk...)
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err == io.EOF && len(line) > 0 {
+			err = nil
+		}
+		s.next++
+		return line, err
+	}
+}
+
+// fail records err, if any, and reports whether there was one. A line past
+// the end of the file is an error too.
+func (s *Stream) fail(err error) bool {
+	if err != nil {
+		s.err = fmt.Errorf("error reading wordlist file %s: %w", s.file.Name(), err)
+	}
+	return err != nil
+}
+
+func (s *Stream) Close() error {
+	return s.file.Close()
+}
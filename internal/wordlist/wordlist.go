@@ -0,0 +1,154 @@
+package wordlist
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"faast-go/internal/config"
+)
+
+// List is a list of words with random access, so the permutation engine can
+// jump to any index without holding the list in memory.
+type List interface {
+	Len() int
+	Get(i int) string
+}
+
+// failingList is a list read from a file, whose reads can fail. Get then
+// returns an empty string and Err the error.
+type failingList interface {
+	List
+	Err() error
+}
+
+// Slice is a list held in memory.
+type Slice []string
+
+func (s Slice) Len() int {
+	return len(s)
+}
+
+func (s Slice) Get(i int) string {
+	return s[i]
+}
+
+// Lists are the wordlists of a run, in field order.
+type Lists []List
+
+// Load opens the list of every field, in order, with the field's rules
+// applied. Wordlist files are not read into memory: the first one, which
+// changes slowest in a cluster bomb, is streamed, the others get an index of
+// their line offsets and are read with ReadAt. Inline values are used as
+// they are and generators compute their values from the index. Close
+// releases the files.
+func Load(fields []config.Field) (Lists, error) {
+	lists := make(Lists, 0, len(fields))
+	streamed := false
+	for _, field := range fields {
+		var list List
+		var err error
+		switch {
+		case len(field.Values) > 0:
+			list = Slice(field.Values)
+		case field.Range != "":
+			list, err = NewRange(field.Range, field.Step)
+		case field.Mask != "":
+			list, err = NewMask(field.Mask, field.Charsets)
+		case field.Dates.From != "":
+			list, err = NewDates(field.Dates)
+		case !streamed:
+			list, err = OpenStream(field.Wordlist)
+			streamed = true
+		default:
+			list, err = Open(field.Wordlist)
+		}
+		if err == nil {
+			list, err = Mutate(list, field.Rules)
+		}
+		if err != nil {
+			lists.Close()
+			return nil, fmt.Errorf("field %s: %w", field.Name, err)
+		}
+		lists = append(lists, list)
+	}
+	return lists, nil
+}
+
+// Err is the first read error of any list, see File.Err.
+func (l Lists) Err() error {
+	for _, list := range l {
+		if list, ok := list.(failingList); ok {
+			if err := list.Err(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (l Lists) Close() error {
+	var errs []error
+	for _, list := range l {
+		if closer, ok := list.(io.Closer); ok {
+			errs = append(errs, closer.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// scanLines calls fn with the offset of every line of r, and returns the
+// number of lines. Like bufio.ScanLines, a final newline does not start
+// another line.
+func scanLines(r io.Reader, fn func(line int, offset int64)) (int, error) {
+	reader := bufio.NewReaderSize(r, 1<<20)
+	var offset int64
+	lines := 0
+	atLineStart := true
+	for {
+		chunk, err := reader.ReadSlice('\n')
+		if len(chunk) > 0 {
+			if atLineStart {
+				fn(lines, offset)
+				lines++
+			}
+			offset += int64(len(chunk))
+			atLineStart = chunk[len(chunk)-1] == '\n'
+		}
+		switch err {
+		case nil, bufio.ErrBufferFull:
+		case io.EOF:
+			return lines, nil
+		default:
+			return lines, err
+		}
+	}
+}
+
+func trimLine(line []byte) string {
+	line = bytes.TrimSuffix(line, []byte("\n"))
+	line = bytes.TrimSuffix(line, []byte("\r"))
+	return string(line)
+}
+
+// openFile opens filename and detects its compression.
+func openFile(filename string) (*os.File, int64, string, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, 0, "", fmt.Errorf("error opening wordlist file %s: %w", filename, err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, 0, "", fmt.Errorf("error opening wordlist file %s: %w", filename, err)
+	}
+	kind, err := detect(file)
+	if err != nil {
+		file.Close()
+		return nil, 0, "", err
+	}
+	return file, info.Size(), kind, nil
+}
@@ -0,0 +1,253 @@
+package wordlist
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"faast-go/internal/config"
+)
+
+func writeFile(t *testing.T, content string) string {
+	t.Helper()
+	filename := filepath.Join(t.TempDir(), "words.txt")
+	if err := os.WriteFile(filename, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to create test wordlist file: %v", err)
+	}
+	return filename
+}
+
+func words(list List) []string {
+	result := []string{}
+	for i := 0; i < list.Len(); i++ {
+		result = append(result, list.Get(i))
+	}
+	return result
+}
+
+var lineTests = []struct {
+	name    string
+	content string
+	want    []string
+}{
+	{"Trailing newline", "a\nb\nc\n", []string{"a", "b", "c"}},
+	{"No trailing newline", "a\nb\nc", []string{"a", "b", "c"}},
+	{"CRLF", "a\r\nb\r\n", []string{"a", "b"}},
+	{"Empty lines", "a\n\n\nb\n", []string{"a", "", "", "b"}},
+	{"Empty file", "", []string{}},
+	{"Single newline", "\n", []string{""}},
+}
+
+func TestOpen(t *testing.T) {
+	for _, tt := range lineTests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := Open(writeFile(t, tt.content))
+			if err != nil {
+				t.Fatalf("Open failed: %v", err)
+			}
+			defer f.Close()
+			if got := words(f); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Got %q, want %q", got, tt.want)
+			}
+			// backwards as well, as the order of Get does not matter
+			for i := f.Len() - 1; i >= 0; i-- {
+				if got := f.Get(i); got != tt.want[i] {
+					t.Errorf("Get(%d) = %q, want %q", i, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestOpenStream(t *testing.T) {
+	for _, tt := range lineTests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := OpenStream(writeFile(t, tt.content))
+			if err != nil {
+				t.Fatalf("OpenStream failed: %v", err)
+			}
+			defer s.Close()
+			if got := words(s); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStream_Seek(t *testing.T) {
+	lines := 3*streamSpacing + 10
+	var content strings.Builder
+	for i := 0; i < lines; i++ {
+		fmt.Fprintf(&content, "word%d\n", i)
+	}
+
+	for _, compressed := range []bool{false, true} {
+		t.Run(fmt.Sprintf("compressed=%v", compressed), func(t *testing.T) {
+			data := []byte(content.String())
+			if compressed {
+				data = gzipped(t, data)
+			}
+			s, err := OpenStream(writeFile(t, string(data)))
+			if err != nil {
+				t.Fatalf("OpenStream failed: %v", err)
+			}
+			defer s.Close()
+
+			if s.Len() != lines {
+				t.Fatalf("Expected %d lines, got %d", lines, s.Len())
+			}
+			for _, i := range []int{0, 5, 5, 4, streamSpacing + 1, streamSpacing - 1, lines - 1, 2 * streamSpacing, 1} {
+				if got, want := s.Get(i), fmt.Sprintf("word%d", i); got != want {
+					t.Errorf("Get(%d) = %q, want %q", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func gzipped(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		t.Fatalf("Failed to compress: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Failed to compress: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// printf 'a\nb\r\nc\n' | bzip2
+var bzipped = []byte{
+	0x42, 0x5a, 0x68, 0x39, 0x31, 0x41, 0x59, 0x26, 0x53, 0x59, 0x7d, 0x27,
+	0x49, 0x54, 0x00, 0x00, 0x02, 0x41, 0x00, 0x00, 0x12, 0x38, 0x00, 0x20,
+	0x00, 0x21, 0x83, 0x41, 0x9a, 0x03, 0x60, 0x7c, 0x5d, 0xc9, 0x14, 0xe1,
+	0x42, 0x41, 0xf4, 0x9d, 0x25, 0x50,
+}
+
+func TestCompressed(t *testing.T) {
+	tests := []struct {
+		name     string
+		filename string
+		content  []byte
+		want     []string
+		wantErr  bool
+	}{
+		{"gzip", "words.txt.gz", gzipped(t, []byte("a\nb\r\nc\n")), []string{"a", "b", "c"}, false},
+		{"gzip detected by magic bytes", "words.txt", gzipped(t, []byte("a\nb\r\nc")), []string{"a", "b", "c"}, false},
+		{"bzip2", "words.txt.bz2", bzipped, []string{"a", "b", "c"}, false},
+		{"bzip2 detected by magic bytes", "words", bzipped, []string{"a", "b", "c"}, false},
+		{"Empty gzip", "words.gz", gzipped(t, nil), []string{}, false},
+		{"Not gzip", "words.gz", []byte("a\nb\n"), nil, true},
+		{"Not bzip2", "words.bz2", []byte("a\nb\n"), nil, true},
+		{"Plain words starting like bzip2", "words.txt", []byte("BZhello\nBZh\n"), []string{"BZhello", "BZh"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), tt.filename)
+			if err := os.WriteFile(filename, tt.content, 0644); err != nil {
+				t.Fatalf("Failed to create test wordlist file: %v", err)
+			}
+
+			f, err := Open(filename)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Open error = %v, wantErr %v", err, tt.wantErr)
+			}
+			s, streamErr := OpenStream(filename)
+			if (streamErr != nil) != tt.wantErr {
+				t.Fatalf("OpenStream error = %v, wantErr %v", streamErr, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			defer f.Close()
+			defer s.Close()
+
+			if got := words(f); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Open: got %q, want %q", got, tt.want)
+			}
+			if got := words(s); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("OpenStream: got %q, want %q", got, tt.want)
+			}
+			// going back restarts the stream
+			if len(tt.want) > 0 && s.Get(0) != tt.want[0] {
+				t.Errorf("OpenStream: Get(0) = %q after reading all, want %q", s.Get(0), tt.want[0])
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	var fields []config.Field
+	for i, list := range [][]string{{"word1", "word2"}, {"word3", "word4"}} {
+		filename := filepath.Join(dir, fmt.Sprintf("wordlist%d.txt", i))
+		if err := os.WriteFile(filename, []byte(strings.Join(list, "\n")+"\n"), 0644); err != nil {
+			t.Fatalf("Failed to create test wordlist file: %v", err)
+		}
+		fields = append(fields, config.Field{Name: fmt.Sprintf("field%d", i), Wordlist: filename})
+	}
+	fields[1].Rules = []string{":", "u"}
+	fields = append([]config.Field{{Name: "pin", Range: "0-2"}, {Name: "lang", Values: []string{"en", "fr"}}}, fields...)
+
+	lists, err := Load(fields)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if _, ok := lists[2].(*Stream); !ok {
+		t.Errorf("Expected the first wordlist to be streamed, got %T", lists[2])
+	}
+	want := [][]string{{"0", "1", "2"}, {"en", "fr"}, {"word1", "word2"}, {"word3", "WORD3", "word4", "WORD4"}}
+	for i, list := range lists {
+		if got := words(list); !reflect.DeepEqual(got, want[i]) {
+			t.Errorf("List %d: got %q, want %q", i, got, want[i])
+		}
+	}
+	if err := lists.Close(); err != nil {
+		t.Errorf("Close failed: %v", err)
+	}
+
+	for _, field := range []config.Field{
+		{Name: "missing", Wordlist: filepath.Join(dir, "non_existent_file.txt")},
+		{Name: "rules", Wordlist: fields[2].Wordlist, Rules: []string{"K"}},
+		{Name: "mask", Mask: "?x"},
+	} {
+		if _, err := Load(append(fields, field)); err == nil {
+			t.Errorf("Load should have returned an error for field %s", field.Name)
+		}
+	}
+}
+
+func TestLists_Err(t *testing.T) {
+	lists, err := Load([]config.Field{
+		{Name: "user", Wordlist: writeFile(t, "admin\nroot\n")},
+		{Name: "pass", Wordlist: writeFile(t, "a\nb\n"), Rules: []string{":", "u"}},
+	})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if err := lists.Err(); err != nil {
+		t.Fatalf("Err() = %v before any read failed", err)
+	}
+
+	// reads of a file that went away fail without a panic
+	lists.Close()
+	for i, list := range lists {
+		if got := list.Get(1); got != "" {
+			t.Errorf("List %d: Get(1) = %q after a failed read, want an empty string", i, got)
+		}
+		if err := list.(failingList).Err(); err == nil || !strings.Contains(err.Error(), "error reading wordlist file") {
+			t.Errorf("List %d: Err() = %v, want the read error", i, err)
+		}
+	}
+	if err := lists.Err(); err == nil {
+		t.Error("Lists.Err() should have returned the read error")
+	}
+}
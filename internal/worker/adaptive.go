@@ -0,0 +1,141 @@
+package worker
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"faast-go/internal/config"
+	"faast-go/internal/curl"
+)
+
+// adaptInterval is how often the adaptive controller looks at the results
+// of the last window and changes the number of active workers.
+const adaptInterval = 2 * time.Second
+
+// controller decides the number of active workers: it adds one worker after
+// every healthy window and halves them after a window with throttling,
+// timeouts or slow responses.
+type controller struct {
+	mu         sync.Mutex
+	min        int
+	max        int
+	maxLatency time.Duration
+
+	requests  int
+	responses int
+	timeouts  int
+	throttled int
+	latency   time.Duration
+	// fastest is the lowest average response time of a window so far. It is
+	// the reference for slow responses when no maxLatency is configured.
+	fastest time.Duration
+}
+
+func newController(settings config.AdaptiveConfig) *controller {
+	maxLatency, _ := time.ParseDuration(settings.MaxLatency)
+	return &controller{
+		min:        max(settings.MinWorkers, 1),
+		max:        max(settings.MaxWorkers, settings.MinWorkers, 1),
+		maxLatency: maxLatency,
+	}
+}
+
+func (c *controller) observe(res *curl.Response, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.requests++
+	if err != nil {
+		switch curl.ErrorKind(err) {
+		case "timeout":
+			c.timeouts++
+		case "throttled":
+			c.throttled++
+		}
+		return
+	}
+	c.responses++
+	c.latency += res.Duration
+	if res.StatusCode == http.StatusTooManyRequests || res.StatusCode == http.StatusServiceUnavailable {
+		c.throttled++
+	}
+}
+
+// adjust returns the number of workers for the next window and starts a new
+// window.
+func (c *controller) adjust(current int) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	defer func() {
+		c.requests, c.responses, c.timeouts, c.throttled, c.latency = 0, 0, 0, 0, 0
+	}()
+
+	if c.requests == 0 {
+		return current
+	}
+
+	slow := false
+	if c.responses > 0 {
+		average := c.latency / time.Duration(c.responses)
+		if c.maxLatency > 0 {
+			slow = average > c.maxLatency
+		} else {
+			slow = c.fastest > 0 && average > 2*c.fastest
+		}
+		if c.fastest == 0 || average < c.fastest {
+			c.fastest = average
+		}
+	}
+
+	// More than 5% of the requests timing out is treated as overload.
+	if c.throttled > 0 || c.timeouts*20 > c.requests || slow {
+		return max(current/2, c.min)
+	}
+	return min(current+1, c.max)
+}
+
+// gate lets only the workers whose id is below the limit take permutations.
+type gate struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	limit  int
+	closed bool
+}
+
+func newGate(limit int) *gate {
+	g := &gate{limit: limit}
+	g.cond = sync.NewCond(&g.mu)
+	return g
+}
+
+// wait blocks while worker id is over the limit. It returns false once the
+// gate is closed.
+func (g *gate) wait(id int) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for id >= g.limit && !g.closed {
+		g.cond.Wait()
+	}
+	return !g.closed
+}
+
+func (g *gate) set(limit int) {
+	g.mu.Lock()
+	g.limit = limit
+	g.mu.Unlock()
+	g.cond.Broadcast()
+}
+
+func (g *gate) get() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.limit
+}
+
+func (g *gate) close() {
+	g.mu.Lock()
+	g.closed = true
+	g.mu.Unlock()
+	g.cond.Broadcast()
+}
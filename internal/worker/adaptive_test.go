@@ -0,0 +1,144 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"faast-go/internal/config"
+	"faast-go/internal/curl"
+)
+
+func TestController_Adjust(t *testing.T) {
+	ok := &curl.Response{StatusCode: 200, Duration: 100 * time.Millisecond}
+	slow := &curl.Response{StatusCode: 200, Duration: time.Second}
+	throttled := &curl.Response{StatusCode: 429, Duration: 10 * time.Millisecond}
+	timeout := fmt.Errorf("error from response: %w", context.DeadlineExceeded)
+
+	type observation struct {
+		res *curl.Response
+		err error
+	}
+	tests := []struct {
+		name         string
+		settings     config.AdaptiveConfig
+		observations []observation
+		current      int
+		want         int
+	}{
+		{
+			name:         "healthy window adds a worker",
+			settings:     config.AdaptiveConfig{MinWorkers: 1, MaxWorkers: 20},
+			observations: []observation{{res: ok}, {res: ok}},
+			current:      10,
+			want:         11,
+		},
+		{
+			name:         "capped at maxWorkers",
+			settings:     config.AdaptiveConfig{MinWorkers: 1, MaxWorkers: 10},
+			observations: []observation{{res: ok}},
+			current:      10,
+			want:         10,
+		},
+		{
+			name:         "throttling halves the workers",
+			settings:     config.AdaptiveConfig{MinWorkers: 1, MaxWorkers: 20},
+			observations: []observation{{res: ok}, {res: throttled}},
+			current:      10,
+			want:         5,
+		},
+		{
+			name:         "timeouts halve the workers",
+			settings:     config.AdaptiveConfig{MinWorkers: 1, MaxWorkers: 20},
+			observations: []observation{{res: ok}, {err: timeout}},
+			current:      10,
+			want:         5,
+		},
+		{
+			name:         "other failures do not count as overload",
+			settings:     config.AdaptiveConfig{MinWorkers: 1, MaxWorkers: 20},
+			observations: []observation{{res: ok}, {err: fmt.Errorf("connection refused")}},
+			current:      10,
+			want:         11,
+		},
+		{
+			name:         "slower than maxLatency",
+			settings:     config.AdaptiveConfig{MinWorkers: 1, MaxWorkers: 20, MaxLatency: "500ms"},
+			observations: []observation{{res: slow}},
+			current:      10,
+			want:         5,
+		},
+		{
+			name:         "never below minWorkers",
+			settings:     config.AdaptiveConfig{MinWorkers: 4, MaxWorkers: 20},
+			observations: []observation{{res: throttled}},
+			current:      5,
+			want:         4,
+		},
+		{
+			name:     "empty window keeps the workers",
+			settings: config.AdaptiveConfig{MinWorkers: 1, MaxWorkers: 20},
+			current:  10,
+			want:     10,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newController(tt.settings)
+			for _, o := range tt.observations {
+				c.observe(o.res, o.err)
+			}
+			if got := c.adjust(tt.current); got != tt.want {
+				t.Errorf("adjust(%d) = %d, want %d", tt.current, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestController_AdjustLatency(t *testing.T) {
+	c := newController(config.AdaptiveConfig{MinWorkers: 1, MaxWorkers: 20})
+
+	c.observe(&curl.Response{StatusCode: 200, Duration: 100 * time.Millisecond}, nil)
+	if got := c.adjust(10); got != 11 {
+		t.Errorf("first window adjust(10) = %d, want 11", got)
+	}
+	c.observe(&curl.Response{StatusCode: 200, Duration: 150 * time.Millisecond}, nil)
+	if got := c.adjust(11); got != 12 {
+		t.Errorf("slightly slower window adjust(11) = %d, want 12", got)
+	}
+	c.observe(&curl.Response{StatusCode: 200, Duration: 300 * time.Millisecond}, nil)
+	if got := c.adjust(12); got != 6 {
+		t.Errorf("window over twice the fastest adjust(12) = %d, want 6", got)
+	}
+}
+
+func TestGate(t *testing.T) {
+	g := newGate(1)
+	if !g.wait(0) {
+		t.Fatal("wait(0) under the limit returned false")
+	}
+
+	released := make(chan bool)
+	go func() {
+		released <- g.wait(1)
+	}()
+	select {
+	case <-released:
+		t.Fatal("wait(1) returned while over the limit")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	g.set(2)
+	if ok := <-released; !ok {
+		t.Error("wait(1) returned false after the limit was raised")
+	}
+
+	go func() {
+		released <- g.wait(5)
+	}()
+	g.close()
+	if ok := <-released; ok {
+		t.Error("wait(5) returned true after close")
+	}
+}
@@ -0,0 +1,122 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"faast-go/internal/config"
+	"faast-go/internal/curl"
+)
+
+// RetryPolicy decides whether a request is sent again, and how long to wait
+// before doing so.
+type RetryPolicy struct {
+	attempts   int
+	backoff    time.Duration
+	maxBackoff time.Duration
+	status     map[int]bool
+	errors     map[string]bool
+}
+
+func NewRetryPolicy(settings config.RetryConfig) *RetryPolicy {
+	p := &RetryPolicy{
+		attempts: max(settings.Attempts, 1),
+		status:   make(map[int]bool, len(settings.Status)),
+		errors:   make(map[string]bool, len(settings.Errors)),
+	}
+	p.backoff, _ = time.ParseDuration(settings.Backoff)
+	p.maxBackoff, _ = time.ParseDuration(settings.MaxBackoff)
+	for _, status := range settings.Status {
+		p.status[status] = true
+	}
+	for _, kind := range settings.Errors {
+		p.errors[kind] = true
+	}
+	return p
+}
+
+func (p *RetryPolicy) retryable(res *curl.Response, err error) bool {
+	if err != nil {
+		return p.errors[curl.ErrorKind(err)]
+	}
+	return p.status[res.StatusCode]
+}
+
+// delay is the wait before the next attempt. A Retry-After header on the
+// response is honoured, otherwise the backoff doubles with every attempt,
+// up to maxBackoff, and half of it is random so workers do not retry in
+// lockstep.
+func (p *RetryPolicy) delay(attempt int, res *curl.Response) time.Duration {
+	if res != nil {
+		if wait, ok := res.RetryAfter(); ok {
+			return wait
+		}
+	}
+	// Doubling step by step stops at maxBackoff, where a shift by the
+	// attempt could wrap around for a large attempt.
+	backoff := min(p.backoff, p.maxBackoff)
+	for i := 1; i < attempt && backoff < p.maxBackoff; i++ {
+		if backoff > p.maxBackoff/2 {
+			backoff = p.maxBackoff
+		} else {
+			backoff *= 2
+		}
+	}
+	if backoff <= 0 {
+		backoff = p.maxBackoff
+	}
+	if backoff <= 1 {
+		return backoff
+	}
+	return backoff/2 + rand.N(backoff/2)
+}
+
+// send sends the payload, retrying it according to the retry policy. Waiting
+// for a retry stops when ctx is cancelled, and the last result is returned.
+// A response with a retryable status on the last attempt is returned with an
+// error, so it is reported as a failure and not matched.
+//
+// Throttled requests are sent again up to the throttle's Retries, without
+// counting as attempts. SendCurl itself waits until the throttle pause ends,
+// and a request whose wait was cancelled is returned as curl.ErrNotSent.
+func (wp *WorkerPool) send(ctx context.Context, requestCtx context.Context, payload *curl.Payload) (*curl.Response, error) {
+	throttled := 0
+	for attempt := 1; ; attempt++ {
+		res, err := wp.config.SendCurl(requestCtx, payload)
+		if errors.Is(err, curl.ErrNotSent) {
+			return nil, err
+		}
+		if wp.adaptive != nil {
+			wp.adaptive.observe(res, err)
+		}
+		if errors.Is(err, curl.ErrThrottled) && throttled < wp.config.Throttle.Retries && ctx.Err() == nil {
+			throttled++
+			attempt--
+			continue
+		}
+		if wp.retry == nil || wp.retry.attempts == 1 || !wp.retry.retryable(res, err) {
+			return res, err
+		}
+		if attempt == wp.retry.attempts || ctx.Err() != nil {
+			return res, giveUp(res, err, attempt)
+		}
+
+		timer := time.NewTimer(wp.retry.delay(attempt, res))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return res, giveUp(res, err, attempt)
+		}
+	}
+}
+
+func giveUp(res *curl.Response, err error, attempts int) error {
+	if err != nil {
+		return fmt.Errorf("failed after %d attempts: %w", attempts, err)
+	}
+	return fmt.Errorf("status %d after %d attempts", res.StatusCode, attempts)
+}
@@ -0,0 +1,127 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"faast-go/internal/config"
+	"faast-go/internal/curl"
+
+	"github.com/schollz/progressbar/v3"
+)
+
+func TestRetryPolicy_Delay(t *testing.T) {
+	p := NewRetryPolicy(config.RetryConfig{Attempts: 5, Backoff: "100ms", MaxBackoff: "300ms"})
+
+	for attempt, want := range map[int]time.Duration{1: 100 * time.Millisecond, 2: 200 * time.Millisecond, 3: 300 * time.Millisecond, 10: 300 * time.Millisecond} {
+		got := p.delay(attempt, nil)
+		if got < want/2 || got > want {
+			t.Errorf("delay(%d) = %v, want between %v and %v", attempt, got, want/2, want)
+		}
+	}
+
+	// 5ns shifted by 62 wraps around to a quarter of the longest duration.
+	long := NewRetryPolicy(config.RetryConfig{Attempts: 100, Backoff: "5ns", MaxBackoff: "2562047h"})
+	for _, attempt := range []int{63, 64, 100} {
+		if got := long.delay(attempt, nil); got < long.maxBackoff/2 {
+			t.Errorf("delay(%d) = %v, want at least %v", attempt, got, long.maxBackoff/2)
+		}
+	}
+
+	res := &curl.Response{Header: http.Header{"Retry-After": {"2"}}}
+	if got := p.delay(1, res); got != 2*time.Second {
+		t.Errorf("delay() with Retry-After = %v, want 2s", got)
+	}
+}
+
+func TestRetryPolicy_Retryable(t *testing.T) {
+	p := NewRetryPolicy(config.RetryConfig{Attempts: 3, Status: []int{503}, Errors: []string{"timeout"}})
+	if !p.retryable(&curl.Response{StatusCode: 503}, nil) {
+		t.Error("503 should be retryable")
+	}
+	if p.retryable(&curl.Response{StatusCode: 500}, nil) {
+		t.Error("500 should not be retryable")
+	}
+	if !p.retryable(nil, context.DeadlineExceeded) {
+		t.Error("a timeout should be retryable")
+	}
+	if p.retryable(nil, http.ErrNoCookie) {
+		t.Error("other failures should not be retryable")
+	}
+}
+
+func TestWorkerPool_Send(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/down") || atomic.AddInt32(&requests, 1) <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	yamlConfig := createTestYamlConfig()
+	yamlConfig.Endpoint = server.URL
+	yamlConfig.RateLimit = 0
+	curlConfig, _ := curl.NewCurlConfig(yamlConfig)
+	wp := NewWorkerPool(curlConfig, nil, nil, progressbar.New(1))
+	wp.SetRetry(NewRetryPolicy(config.RetryConfig{Attempts: 3, Backoff: "1ms", MaxBackoff: "5ms", Status: []int{503}}))
+
+	ctx := context.Background()
+	res, err := wp.send(ctx, ctx, &curl.Payload{Method: "GET", URL: server.URL, Header: http.Header{}})
+	if err != nil || res.StatusCode != 200 {
+		t.Fatalf("send() = %v, %v, want 200 on the third attempt", res, err)
+	}
+	if requests != 3 {
+		t.Errorf("send() made %d requests, want 3", requests)
+	}
+
+	res, err = wp.send(ctx, ctx, &curl.Payload{Method: "GET", URL: server.URL + "/down", Header: http.Header{}})
+	if err == nil || err.Error() != "status 503 after 3 attempts" {
+		t.Errorf("send() error = %v, want status 503 after 3 attempts", err)
+	}
+	if res == nil || res.StatusCode != 503 {
+		t.Errorf("send() should return the last response, got %v", res)
+	}
+}
+
+func TestWorkerPool_SendThrottled(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) <= 2 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	yamlConfig := createTestYamlConfig()
+	yamlConfig.Endpoint = server.URL
+	yamlConfig.RateLimit = 1000
+	yamlConfig.Throttle = config.ThrottleConfig{Status: []int{429}, Pause: "1ms", MinRate: 1000, Retries: 2}
+	curlConfig, _ := curl.NewCurlConfig(yamlConfig)
+	wp := NewWorkerPool(curlConfig, nil, nil, progressbar.New(1))
+	wp.SetRetry(NewRetryPolicy(config.RetryConfig{Attempts: 1}))
+
+	ctx := context.Background()
+	res, err := wp.send(ctx, ctx, &curl.Payload{Method: "GET", URL: server.URL, Header: http.Header{}})
+	if err != nil || res.StatusCode != 200 {
+		t.Fatalf("send() = %v, %v, want 200 after two throttled responses", res, err)
+	}
+
+	atomic.StoreInt32(&requests, 0)
+	curlConfig.Throttle.Retries = 1
+	_, err = wp.send(ctx, ctx, &curl.Payload{Method: "GET", URL: server.URL, Header: http.Header{}})
+	if !errors.Is(err, curl.ErrThrottled) {
+		t.Errorf("send() error = %v, want ErrThrottled once the throttle retries are used up", err)
+	}
+}
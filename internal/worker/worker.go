@@ -2,49 +2,115 @@ package worker
 
 import (
 	"context"
-	"net/http"
+	"errors"
+	"fmt"
 	"sync"
 	"sync/atomic"
+	"time"
 
+	"faast-go/internal/config"
 	"faast-go/internal/curl"
+	"faast-go/internal/permute"
 
 	"github.com/schollz/progressbar/v3"
 )
 
+// CurlResult is the outcome of one permutation. Request is the request that
+// was sent, nil when it could not be built.
 type CurlResult struct {
+	Index    int
 	Payload  []string
-	Response *http.Response
+	Request  *curl.Payload
+	Response *curl.Response
 	Err      error
 }
 
 type WorkerPool struct {
 	config      *curl.CurlConfig
-	permChan    <-chan []string
+	permChan    <-chan permute.Permutation
 	resultChan  chan<- CurlResult
 	progressBar *progressbar.ProgressBar
 	numWorkers  int
 	wg          sync.WaitGroup
 	workerCount int32
+	gate        *gate
+	adaptive    *controller
+	retry       *RetryPolicy
 }
 
-func NewWorkerPool(config *curl.CurlConfig, permChan <-chan []string, resultChan chan<- CurlResult, progressBar *progressbar.ProgressBar) *WorkerPool {
+func NewWorkerPool(config *curl.CurlConfig, permChan <-chan permute.Permutation, resultChan chan<- CurlResult, progressBar *progressbar.ProgressBar) *WorkerPool {
 	return &WorkerPool{
 		config:      config,
 		permChan:    permChan,
 		resultChan:  resultChan,
 		progressBar: progressBar,
 		numWorkers:  10, // Adjust based on your needs and rate limits
+		gate:        newGate(10),
 	}
 }
 
-func (wp *WorkerPool) Start() {
-	for i := 0; i < wp.numWorkers; i++ {
+func (wp *WorkerPool) SetWorkers(numWorkers int) {
+	wp.numWorkers = numWorkers
+}
+
+// SetAdaptive makes the pool start with numWorkers active workers and change
+// that number while running, see config.AdaptiveConfig.
+func (wp *WorkerPool) SetAdaptive(settings config.AdaptiveConfig) {
+	wp.adaptive = newController(settings)
+}
+
+func (wp *WorkerPool) SetRetry(policy *RetryPolicy) {
+	wp.retry = policy
+}
+
+// ActiveWorkers is the number of workers currently allowed to send requests.
+func (wp *WorkerPool) ActiveWorkers() int {
+	return wp.gate.get()
+}
+
+// Start launches the workers. Once ctx is cancelled the workers stop taking
+// new permutations, but requests already in flight are finished and their
+// results delivered. Requests still waiting for the rate limiter are dropped
+// without a result, so they are left to do.
+func (wp *WorkerPool) Start(ctx context.Context) {
+	active, total := wp.numWorkers, wp.numWorkers
+	if wp.adaptive != nil {
+		active = min(max(wp.numWorkers, wp.adaptive.min), wp.adaptive.max)
+		total = wp.adaptive.max
+	}
+	wp.gate.set(active)
+	context.AfterFunc(ctx, wp.gate.close)
+	for i := 0; i < total; i++ {
 		wp.wg.Add(1)
-		go func() {
+		go func(id int) {
 			defer wp.wg.Done()
 			atomic.AddInt32(&wp.workerCount, 1)
-			wp.worker()
+			wp.worker(ctx, id)
+		}(i)
+	}
+
+	if wp.adaptive != nil {
+		done := make(chan struct{})
+		go func() {
+			wp.wg.Wait()
+			close(done)
 		}()
+		go wp.adapt(done)
+	}
+}
+
+func (wp *WorkerPool) adapt(done <-chan struct{}) {
+	ticker := time.NewTicker(adaptInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			active := wp.adaptive.adjust(wp.gate.get())
+			wp.gate.set(active)
+			wp.progressBar.Describe(fmt.Sprintf("%d workers", active))
+		case <-done:
+			return
+		}
 	}
 }
 
@@ -52,15 +118,33 @@ func (wp *WorkerPool) Wait() {
 	wp.wg.Wait()
 }
 
-func (wp *WorkerPool) worker() {
-	for perm := range wp.permChan {
-		payload, err := wp.config.ConstructPayload(perm)
+func (wp *WorkerPool) worker(ctx context.Context, id int) {
+	requestCtx := curl.WithWorker(ctx, id)
+	for ctx.Err() == nil && wp.gate.wait(id) {
+		var perm permute.Permutation
+		select {
+		case <-ctx.Done():
+			return
+		case next, ok := <-wp.permChan:
+			if !ok {
+				// Release the workers waiting at the gate, there is no more
+				// work for them.
+				wp.gate.close()
+				return
+			}
+			perm = next
+		}
+
+		payload, err := wp.config.ConstructPayload(perm.Values)
 		if err != nil {
-			wp.resultChan <- CurlResult{Payload: perm, Err: err}
+			wp.resultChan <- CurlResult{Index: perm.Index, Payload: perm.Values, Err: err}
+			continue
+		}
+		res, err := wp.send(ctx, requestCtx, payload)
+		if errors.Is(err, curl.ErrNotSent) {
 			continue
 		}
-		res, err := wp.config.SendCurl(context.Background(), payload)
 		wp.progressBar.Add(1)
-		wp.resultChan <- CurlResult{Payload: perm, Response: res, Err: err}
+		wp.resultChan <- CurlResult{Index: perm.Index, Payload: perm.Values, Request: payload, Response: res, Err: err}
 	}
 }
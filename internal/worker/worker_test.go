@@ -1,8 +1,10 @@
 package worker
 
 import (
+	"context"
 	"faast-go/internal/config"
 	"faast-go/internal/curl"
+	"faast-go/internal/permute"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
@@ -15,11 +17,12 @@ import (
 
 func createTestYamlConfig() *config.YamlConfig {
 	return &config.YamlConfig{
-		Type:         "payload",
-		Endpoint:     "http://example.com",
-		Fields:       []string{"field1", "field2"},
-		Wordlists:    []string{"wordlist1.txt"},
-		StaticValues: []string{"staticValue"},
+		Type:     "payload",
+		Endpoint: "http://example.com",
+		Fields: []config.Field{
+			{Name: "field1", Wordlist: "wordlist1.txt"},
+			{Name: "field2", Value: "staticValue"},
+		},
 		Cookies:      []string{"session=abc123"},
 		ValidateType: "code",
 		SizeDefault:  100,
@@ -38,7 +41,7 @@ func TestNewWorkerPool(t *testing.T) {
 		t.Fatalf("Failed to create CurlConfig: %v", err)
 	}
 
-	permChan := make(chan []string)
+	permChan := make(chan permute.Permutation)
 	resultChan := make(chan CurlResult)
 	progressBar := progressbar.New(100)
 
@@ -64,14 +67,14 @@ func TestNewWorkerPool(t *testing.T) {
 func TestWorkerPool_Start(t *testing.T) {
 	yamlConfig := createTestYamlConfig()
 	curlConfig, _ := curl.NewCurlConfig(yamlConfig)
-	permChan := make(chan []string)
+	permChan := make(chan permute.Permutation)
 	resultChan := make(chan CurlResult)
 	progressBar := progressbar.New(100)
 
 	wp := NewWorkerPool(curlConfig, permChan, resultChan, progressBar)
 	wp.numWorkers = 3 // Reduce number of workers for testing
 
-	wp.Start()
+	wp.Start(context.Background())
 
 	// Check if the correct number of workers were started
 	time.Sleep(100 * time.Millisecond) // Give some time for goroutines to start
@@ -86,14 +89,14 @@ func TestWorkerPool_Start(t *testing.T) {
 func TestWorkerPool_Wait(t *testing.T) {
 	yamlConfig := createTestYamlConfig()
 	curlConfig, _ := curl.NewCurlConfig(yamlConfig)
-	permChan := make(chan []string)
+	permChan := make(chan permute.Permutation)
 	resultChan := make(chan CurlResult)
 	progressBar := progressbar.New(100)
 
 	wp := NewWorkerPool(curlConfig, permChan, resultChan, progressBar)
 	wp.numWorkers = 3 // Reduce number of workers for testing
 
-	wp.Start()
+	wp.Start(context.Background())
 	close(permChan) // This will cause workers to finish
 
 	done := make(chan struct{})
@@ -120,16 +123,16 @@ func TestWorkerPool_worker(t *testing.T) {
 	yamlConfig.Endpoint = server.URL
 	curlConfig, _ := curl.NewCurlConfig(yamlConfig)
 
-	permChan := make(chan []string)
+	permChan := make(chan permute.Permutation)
 	resultChan := make(chan CurlResult)
 	progressBar := progressbar.New(2)
 
 	wp := NewWorkerPool(curlConfig, permChan, resultChan, progressBar)
 
-	go wp.worker()
+	go wp.worker(context.Background(), 0)
 
 	// Test successful case
-	permChan <- []string{"test1"}
+	permChan <- permute.Permutation{Index: 7, Values: []string{"test1"}}
 
 	select {
 	case result := <-resultChan:
@@ -139,6 +142,9 @@ func TestWorkerPool_worker(t *testing.T) {
 		if !reflect.DeepEqual(result.Payload, []string{"test1"}) {
 			t.Errorf("Expected payload [test1], got %v", result.Payload)
 		}
+		if result.Index != 7 {
+			t.Errorf("Expected index 7, got %d", result.Index)
+		}
 		if result.Response.StatusCode != 200 {
 			t.Errorf("Expected status code 200, got %d", result.Response.StatusCode)
 		}
@@ -147,7 +153,7 @@ func TestWorkerPool_worker(t *testing.T) {
 	}
 
 	// Test error case (invalid permutation length)
-	permChan <- []string{"test2", "extraValue"}
+	permChan <- permute.Permutation{Index: 8, Values: []string{"test2", "extraValue"}}
 
 	select {
 	case result := <-resultChan:
@@ -163,3 +169,130 @@ func TestWorkerPool_worker(t *testing.T) {
 
 	close(permChan)
 }
+
+func TestWorkerPool_Cancel(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	yamlConfig := createTestYamlConfig()
+	yamlConfig.Endpoint = server.URL
+	curlConfig, _ := curl.NewCurlConfig(yamlConfig)
+
+	permChan := make(chan permute.Permutation, 2)
+	resultChan := make(chan CurlResult, 2)
+	progressBar := progressbar.New(2)
+
+	wp := NewWorkerPool(curlConfig, permChan, resultChan, progressBar)
+	wp.numWorkers = 1
+
+	ctx, cancel := context.WithCancel(context.Background())
+	permChan <- permute.Permutation{Index: 0, Values: []string{"test1"}}
+	wp.Start(ctx)
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	permChan <- permute.Permutation{Index: 1, Values: []string{"test2"}}
+	wp.Wait()
+	close(resultChan)
+
+	var results []CurlResult
+	for result := range resultChan {
+		results = append(results, result)
+	}
+	if len(results) != 1 {
+		t.Fatalf("Expected 1 result after cancel, got %d", len(results))
+	}
+	if results[0].Err != nil || results[0].Index != 0 {
+		t.Errorf("Expected the in-flight request to finish, got %+v", results[0])
+	}
+}
+
+func TestWorkerPool_CancelWhileWaiting(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	yamlConfig := createTestYamlConfig()
+	yamlConfig.Endpoint = server.URL
+	yamlConfig.RateLimit = 1
+	curlConfig, _ := curl.NewCurlConfig(yamlConfig)
+
+	permChan := make(chan permute.Permutation, 2)
+	resultChan := make(chan CurlResult, 2)
+	wp := NewWorkerPool(curlConfig, permChan, resultChan, progressbar.New(2))
+	wp.numWorkers = 1
+
+	ctx, cancel := context.WithCancel(context.Background())
+	permChan <- permute.Permutation{Index: 0, Values: []string{"test1"}}
+	permChan <- permute.Permutation{Index: 1, Values: []string{"test2"}}
+	close(permChan)
+	wp.Start(ctx)
+	time.Sleep(100 * time.Millisecond)
+	start := time.Now()
+	cancel()
+	wp.Wait()
+	close(resultChan)
+
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Wait() took %v, the rate limiter wait should stop on cancel", elapsed)
+	}
+	var results []CurlResult
+	for result := range resultChan {
+		results = append(results, result)
+	}
+	// the second request was never sent, so it has no result and is left to do
+	if len(results) != 1 || results[0].Index != 0 || results[0].Err != nil {
+		t.Errorf("Expected only the sent request as a result, got %+v", results)
+	}
+}
+
+func TestWorkerPool_Adaptive(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	yamlConfig := createTestYamlConfig()
+	yamlConfig.Endpoint = server.URL
+	yamlConfig.RateLimit = 0
+	curlConfig, _ := curl.NewCurlConfig(yamlConfig)
+
+	permChan := make(chan permute.Permutation, 5)
+	resultChan := make(chan CurlResult, 5)
+	progressBar := progressbar.New(5)
+
+	wp := NewWorkerPool(curlConfig, permChan, resultChan, progressBar)
+	wp.SetWorkers(2)
+	wp.SetAdaptive(config.AdaptiveConfig{MinWorkers: 1, MaxWorkers: 6})
+	wp.Start(context.Background())
+
+	if got := wp.ActiveWorkers(); got != 2 {
+		t.Errorf("Expected 2 active workers, got %d", got)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if got := int(atomic.LoadInt32(&wp.workerCount)); got != 6 {
+		t.Errorf("Expected 6 workers to be started, got %d", got)
+	}
+
+	for i := 0; i < 5; i++ {
+		permChan <- permute.Permutation{Index: i, Values: []string{"test"}}
+	}
+	close(permChan)
+
+	done := make(chan struct{})
+	go func() {
+		wp.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Wait timed out with idle workers at the gate")
+	}
+	if len(resultChan) != 5 {
+		t.Errorf("Expected 5 results, got %d", len(resultChan))
+	}
+}